@@ -0,0 +1,136 @@
+package sveltish
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An attrProp describes an attribute that has to be set as a DOM property.
+type attrProp struct {
+	propName  string
+	appliesTo []string
+}
+
+// attrProps is the attribute-to-property table, an empty appliesTo means the
+// property exists on every element.
+var attrProps = map[string]attrProp{
+	"allowfullscreen":     {"allowFullscreen", []string{"iframe"}},
+	"allowpaymentrequest": {"allowPaymentRequest", []string{"iframe"}},
+	"async":               {"async", []string{"script"}},
+	"autofocus":           {"autofocus", []string{"button", "input", "keygen", "select", "textarea"}},
+	"autoplay":            {"autoplay", []string{"audio", "video"}},
+	"checked":             {"checked", []string{"input"}},
+	"controls":            {"controls", []string{"audio", "video"}},
+	"default":             {"default", []string{"track"}},
+	"defer":               {"defer", []string{"script"}},
+	"disabled":            {"disabled", []string{"button", "fieldset", "input", "keygen", "optgroup", "option", "select", "textarea"}},
+	"formnovalidate":      {"formNoValidate", []string{"button", "input"}},
+	"hidden":              {"hidden", nil},
+	"indeterminate":       {"indeterminate", []string{"input"}},
+	"ismap":               {"isMap", []string{"img"}},
+	"loop":                {"loop", []string{"audio", "bgsound", "video"}},
+	"multiple":            {"multiple", []string{"input", "select"}},
+	"muted":               {"muted", []string{"audio", "video"}},
+	"nomodule":            {"noModule", []string{"script"}},
+	"novalidate":          {"noValidate", []string{"form"}},
+	"open":                {"open", []string{"details", "dialog"}},
+	"playsinline":         {"playsInline", []string{"video"}},
+	"readonly":            {"readOnly", []string{"input", "textarea"}},
+	"required":            {"required", []string{"input", "select", "textarea"}},
+	"reversed":            {"reversed", []string{"ol"}},
+	"selected":            {"selected", []string{"option"}},
+	"value":               {"value", []string{"button", "option", "input", "li", "meter", "progress", "param", "select", "textarea"}},
+}
+
+// booleanAttrs are the attributes that are either present or removed,
+// regardless of the value they are given.
+var booleanAttrs = map[string]bool{
+	"allowfullscreen":     true,
+	"allowpaymentrequest": true,
+	"async":               true,
+	"autofocus":           true,
+	"autoplay":            true,
+	"checked":             true,
+	"controls":            true,
+	"default":             true,
+	"defer":               true,
+	"disabled":            true,
+	"formnovalidate":      true,
+	"hidden":              true,
+	"indeterminate":       true,
+	"ismap":               true,
+	"loop":                true,
+	"multiple":            true,
+	"muted":               true,
+	"nomodule":            true,
+	"novalidate":          true,
+	"open":                true,
+	"playsinline":         true,
+	"readonly":            true,
+	"required":            true,
+	"reversed":            true,
+	"selected":            true,
+}
+
+// namespacedAttrSetters are the runtime functions used to set attributes with
+// a namespace prefix.
+var namespacedAttrSetters = map[string]string{
+	"xlink": "xlink_attr",
+}
+
+// propFor finds the DOM property an attribute has to be set with on a tag.
+func propFor(tag, name string) (string, bool) {
+	prop, exists := attrProps[strings.ToLower(name)]
+	if !exists {
+		return "", false
+	}
+	if len(prop.appliesTo) == 0 {
+		return prop.propName, true
+	}
+
+	for _, t := range prop.appliesTo {
+		if t == tag {
+			return prop.propName, true
+		}
+	}
+	return "", false
+}
+
+// isCustomElementTag checks if the tag is a custom element (which always has a
+// hyphen in it's name).
+func isCustomElementTag(tag string) bool {
+	return strings.Contains(tag, "-")
+}
+
+// isBooleanAttr checks if the attribute is only ever present or removed.
+func isBooleanAttr(name string) bool {
+	return booleanAttrs[strings.ToLower(name)]
+}
+
+// attrSetter creates the js statement that will set the named attribute of the
+// node to the value.
+func attrSetter(nodeName, tag, name, value string) string {
+	if isCustomElementTag(tag) {
+		return fmt.Sprintf("set_custom_element_data(%s, '%s', %s)", nodeName, name, value)
+	}
+
+	if ns := strings.SplitN(name, ":", 2); len(ns) == 2 {
+		if setter, exists := namespacedAttrSetters[ns[0]]; exists {
+			return fmt.Sprintf("%s(%s, '%s', %s)", setter, nodeName, name, value)
+		}
+	}
+
+	if prop, exists := propFor(tag, name); exists {
+		if prop == "value" && (tag == "input" || tag == "textarea") {
+			return fmt.Sprintf("set_input_value(%s, %s)", nodeName, value)
+		}
+
+		return fmt.Sprintf("%s.%s = %s", nodeName, prop, value)
+	}
+
+	if isBooleanAttr(name) {
+		return fmt.Sprintf("attr(%s, '%s', (%s) ? '' : null)", nodeName, name, value)
+	}
+
+	return fmt.Sprintf("attr(%s, '%s', %s)", nodeName, name, value)
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path"
+	"regexp"
+	"sync"
 
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/progrium/sveltish"
+	"github.com/progrium/sveltish/runtime"
 )
 
 func logging(logger *log.Logger) func(http.Handler) http.Handler {
@@ -21,12 +29,120 @@ func logging(logger *log.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// runtimeNamespace is the esbuild namespace of the files of the embedded
+// runtime.
+const runtimeNamespace = "sveltish-runtime"
+
+var compileOptions = sveltish.CompileOptions{Runtime: sveltish.DefaultRuntime}
+
+// svelteModules are the files of the embedded runtime that the modules of
+// svelte resolve to, so components can import from them like in svelte.
+var svelteModules = map[string]string{
+	"svelte":            "svelte.ts",
+	"svelte/internal":   "index.ts",
+	"svelte/store":      "store.ts",
+	"svelte/transition": "transition.ts",
+	"svelte/animate":    "animate.ts",
+	"svelte/easing":     "easing.ts",
+	"svelte/motion":     "motion.ts",
+}
+
+// runtimePlugin serves the runtime embedded in the module for the runtime
+// specifier of the compile options and the modules of svelte, so the
+// components don't need a copy of the runtime beside them.
+var runtimePlugin = api.Plugin{
+	Name: "runtime",
+	Setup: func(build api.PluginBuild) {
+		build.OnResolve(api.OnResolveOptions{Filter: "^" + regexp.QuoteMeta(compileOptions.Runtime) + "$"},
+			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				return api.OnResolveResult{Path: "index.ts", Namespace: runtimeNamespace}, nil
+			})
+		build.OnResolve(api.OnResolveOptions{Filter: `^svelte(/|$)`},
+			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				file, ok := svelteModules[args.Path]
+				if !ok {
+					return api.OnResolveResult{}, errors.New("Unsupported svelte module, " + args.Path)
+				}
+				return api.OnResolveResult{Path: file, Namespace: runtimeNamespace}, nil
+			})
+		build.OnResolve(api.OnResolveOptions{Filter: `^\.\.?/`, Namespace: runtimeNamespace},
+			func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				p := path.Join(path.Dir(args.Importer), args.Path)
+				for _, file := range []string{p + ".ts", path.Join(p, "index.ts"), p} {
+					if _, err := fs.Stat(runtime.FS, file); err == nil {
+						return api.OnResolveResult{Path: file, Namespace: runtimeNamespace}, nil
+					}
+				}
+				return api.OnResolveResult{}, errors.New("Could not find the runtime file, " + args.Path)
+			})
+		build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: runtimeNamespace},
+			func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := runtime.FS.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				contents := string(data)
+				return api.OnLoadResult{Contents: &contents, Loader: api.LoaderTS}, nil
+			})
+	},
+}
+
+// runtimeExports gets the names the embedded runtime exports, from the
+// metafile of bundling it.
+func runtimeExports() ([]string, error) {
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{compileOptions.Runtime},
+		Bundle:      true,
+		Format:      api.FormatESModule,
+		Outfile:     "runtime.js",
+		Metafile:    true,
+		Plugins:     []api.Plugin{runtimePlugin},
+	})
+	if len(result.Errors) > 0 {
+		return nil, errors.New("Could not build the runtime, " + result.Errors[0].Text)
+	}
+
+	metafile := struct {
+		Outputs map[string]struct {
+			Exports []string `json:"exports"`
+		} `json:"outputs"`
+	}{}
+	if err := json.Unmarshal([]byte(result.Metafile), &metafile); err != nil {
+		return nil, err
+	}
+
+	exports := []string{}
+	for _, output := range metafile.Outputs {
+		exports = append(exports, output.Exports...)
+	}
+	return exports, nil
+}
+
 var elemPlugin = api.Plugin{
 	Name: "elem",
 	Setup: func(build api.PluginBuild) {
+		var (
+			once       sync.Once
+			exports    []string
+			exportsErr error
+		)
 		build.OnLoad(api.OnLoadOptions{Filter: `\.elem$`},
 			func(args api.OnLoadArgs) (api.OnLoadResult, error) {
-				bytes, err := sveltish.Build(args.Path)
+				c, err := sveltish.ParseFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+
+				once.Do(func() {
+					exports, exportsErr = runtimeExports()
+				})
+				if exportsErr != nil {
+					return api.OnLoadResult{}, exportsErr
+				}
+
+				opts := compileOptions
+				opts.RuntimeExports = exports
+				bytes, err := sveltish.GenerateJS(c, opts)
 				if err != nil {
 					return api.OnLoadResult{}, err
 				}
@@ -37,6 +153,9 @@ var elemPlugin = api.Plugin{
 }
 
 func main() {
+	flag.StringVar(&compileOptions.Runtime, "runtime", sveltish.DefaultRuntime, "the module specifier the components import the runtime from")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "http: ", log.LstdFlags)
 	logger.Println("building...")
 
@@ -44,7 +163,7 @@ func main() {
 		EntryPoints: []string{"example/src/main.js"},
 		Bundle:      true,
 		Outfile:     "example/public/build/main.js",
-		Plugins:     []api.Plugin{elemPlugin},
+		Plugins:     []api.Plugin{elemPlugin, runtimePlugin},
 		Write:       true,
 	})
 
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// bundleTest bundles the entry js with the component beside it, with the
+// plugins of the cli.
+func bundleTest(t *testing.T, component string) api.BuildResult {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.js":  "import App from \"./App.elem\";\nnew App({ target: document.body });\n",
+		"App.elem": component,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return api.Build(api.BuildOptions{
+		EntryPoints: []string{filepath.Join(dir, "main.js")},
+		Bundle:      true,
+		Outfile:     filepath.Join(dir, "build", "main.js"),
+		Plugins:     []api.Plugin{elemPlugin, runtimePlugin},
+	})
+}
+
+func TestBundleSvelteModules(t *testing.T) {
+	result := bundleTest(t, `<script>
+	import { onMount } from "svelte";
+	import { writable } from "svelte/store";
+	import { fade } from "svelte/transition";
+	import { tweened } from "svelte/motion";
+	import { cubicOut } from "svelte/easing";
+	import { flip } from "svelte/animate";
+
+	const count = writable(0);
+	const progress = tweened(0, { easing: cubicOut });
+	onMount(() => console.log(fade, flip));
+</script>
+<p>{$count} {$progress}</p>`)
+	for _, err := range result.Errors {
+		t.Errorf("Expected no errors bundling, got %q", err.Text)
+	}
+	if len(result.OutputFiles) != 1 {
+		t.Fatalf("Expected 1 output file, got %d", len(result.OutputFiles))
+	}
+
+	out := string(result.OutputFiles[0].Contents)
+	for _, fn := range []string{"onMount", "writable", "fade", "tweened", "cubicOut", "flip"} {
+		if !strings.Contains(out, "function "+fn+"(") {
+			t.Errorf("Expected the bundle to contain %s from the runtime", fn)
+		}
+	}
+}
+
+func TestBundleUnsupportedSvelteModule(t *testing.T) {
+	result := bundleTest(t, `<script>
+	import { compile } from "svelte/compiler";
+	console.log(compile);
+</script>
+<p>Hi</p>`)
+	if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Text, "Unsupported svelte module, svelte/compiler") {
+		t.Fatalf("Expected an unsupported svelte module error, got %v", result.Errors)
+	}
+}
@@ -3,27 +3,36 @@ package sveltish
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/progrium/sveltish/internal/html"
 	"github.com/progrium/sveltish/internal/js"
 )
 
 type Component struct {
-	Name string
-	JS   *js.Script
-	CSS  []*html.LeafElNode
-	HTML []*NodeVar
+	Name     string
+	JS       *js.Script
+	ModuleJS *js.Script
+	CSS      []*html.LeafElNode
+	HTML     []*NodeVar
+	Targets  []*TargetNode
+	Title    *html.ElNode
+	Options  Options
 }
 
 func NewComponent(name string, doc *html.Doc) (*Component, error) {
 	c := &Component{
-		Name: name,
-		JS:   nil,
-		CSS:  []*html.LeafElNode{},
-		HTML: []*NodeVar{},
+		Name:    name,
+		JS:      nil,
+		CSS:     []*html.LeafElNode{},
+		HTML:    []*NodeVar{},
+		Targets: []*TargetNode{},
 	}
 	nt := newNameTracker()
+	hasHead := false
+	hasOptions := false
 	err := html.Walk(doc, func(n html.Node, ps html.Parents) (bool, error) {
 		switch ps.Depth() {
 		case 0:
@@ -32,6 +41,19 @@ func NewComponent(name string, doc *html.Doc) (*Component, error) {
 			if ln, ok := n.(*html.LeafElNode); ok {
 				switch ln.Tag() {
 				case "script":
+					if isModuleScript(ln) {
+						if c.ModuleJS != nil {
+							return false, errors.New("More than one <script context=\"module\" /> element found")
+						}
+
+						script, err := js.Parse(strings.NewReader(ln.Content()))
+						if err != nil {
+							return false, err
+						}
+						c.ModuleJS = script
+						return false, nil
+					}
+
 					if c.JS != nil {
 						return false, errors.New("More than one <script /> element found")
 					}
@@ -47,12 +69,110 @@ func NewComponent(name string, doc *html.Doc) (*Component, error) {
 					return false, nil
 				}
 			}
+			if en, ok := n.(*html.ElNode); ok {
+				if target, exists := specialTargets[en.Tag()]; exists {
+					tn, err := newTargetNode(target, en)
+					if err != nil {
+						return false, err
+					}
+					for _, existing := range c.Targets {
+						if existing.target == target {
+							return false, errors.New("A component can only have one <" + en.Tag() + " /> element")
+						}
+					}
+
+					c.Targets = append(c.Targets, tn)
+					return false, nil
+				}
+				if en.Tag() == optionsTag {
+					if hasOptions {
+						return false, errors.New("A component can only have one <" + optionsTag + " /> element")
+					}
+
+					opts, err := newOptions(en)
+					if err != nil {
+						return false, err
+					}
+
+					hasOptions = true
+					c.Options = opts
+					return false, nil
+				}
+				if en.Tag() == headTag {
+					if hasHead {
+						return false, errors.New("A component can only have one <" + headTag + " /> element")
+					}
+
+					hasHead = true
+					return true, nil
+				}
+			}
 		default:
 			if ln, ok := n.(*html.LeafElNode); ok {
 				if tag := ln.Tag(); tag == "script" || tag == "style" {
 					return false, errors.New("Connot add <script /> or <style /> element other than as a root element")
 				}
 			}
+			if en, ok := n.(*html.ElNode); ok {
+				if _, exists := specialTargets[en.Tag()]; exists || en.Tag() == headTag || en.Tag() == optionsTag {
+					return false, errors.New("Cannot add <" + en.Tag() + " /> element other than as a root element")
+				}
+			}
+			if ps.Depth() == 2 && isHeadChild(ps) {
+				if en, ok := n.(*html.ElNode); ok && en.Tag() == "title" {
+					c.Title = en
+					return false, nil
+				}
+				if tn, ok := n.(*html.TxtNode); ok && html.IsContentWhiteSpace(tn) {
+					return false, nil
+				}
+			}
+		}
+
+		if en, ok := n.(*html.ElNode); ok {
+			switch en.Tag() {
+			case selfTag:
+				if !isInsideComponent(ps) {
+					return false, errors.New("<" + selfTag + " /> can only be used inside the slots passed to a component")
+				}
+			case componentTag, elementTag:
+				if _, exists := thisAttrOf(en); !exists {
+					return false, errors.New("<" + en.Tag() + " /> must have a 'this' attribute")
+				}
+			case fragmentTag:
+				if parent, _ := ps.Parent(); !isComponent(parent) {
+					return false, errors.New("<" + fragmentTag + " /> must be the direct child of a component")
+				}
+				for _, attr := range en.Attrs() {
+					if _, hasDir := attr.Dir(); !(hasDir && attr.Name() == "let") && !(!hasDir && attr.Name() == "slot") {
+						return false, errors.New("<" + fragmentTag + " /> can only have a slot attribute and let: directives")
+					}
+				}
+			}
+
+			for _, attr := range en.Attrs() {
+				if dir, hasDir := attr.Dir(); hasDir && attr.Name() == "let" {
+					if alias := optionValue(attr); alias != "" && !isIdentifier(alias) {
+						return false, errors.New("The value of let:" + dir + " must be a variable name")
+					}
+				}
+			}
+
+			if isComponent(en) {
+				slotNames := map[string]bool{}
+				for _, child := range en.Children() {
+					el, ok := child.(html.Element)
+					if !ok {
+						continue
+					}
+					if slotName, exists := slotNameOf(el); exists {
+						if slotNames[slotName] {
+							return false, errors.New("Duplicate slot name '" + slotName + "' in <" + en.Tag() + " />")
+						}
+						slotNames[slotName] = true
+					}
+				}
+			}
 		}
 
 		if err := nt.addTrackingFor(n); err != nil {
@@ -64,42 +184,307 @@ func NewComponent(name string, doc *html.Doc) (*Component, error) {
 		return c, err
 	}
 
-	html.Walk(doc, func(n html.Node, ps html.Parents) (bool, error) {
-		switch ps.Depth() {
-		case 0:
+	c.Name = componentName(name)
+	for _, script := range []*js.Script{c.JS, c.ModuleJS} {
+		if script == nil {
+			continue
+		}
+
+		names := script.Names()
+		nt.reserve(names...)
+		for i := 1; containsString(names, c.Name) || containsString(runtimeHelpers, c.Name); i++ {
+			c.Name = fmt.Sprintf("%s_%d", componentName(name), i)
+		}
+	}
+	nt.reserve(c.Name)
+
+	if c.JS != nil {
+		nrw := js.NewVarNameRewriter(nil, nil)
+		html.Walk(doc, func(n html.Node, _ html.Parents) (bool, error) {
+			switch node := n.(type) {
+			case *html.ExprNode:
+				data, _ := node.RewriteJs(nrw)
+				c.JS.UseRefs(data)
+			case html.Element:
+				for _, attr := range node.Attrs() {
+					data, _ := attr.RewriteJs(nrw)
+					c.JS.UseRefs(data)
+				}
+			}
 			return true, nil
-		case 1:
-			if ln, ok := n.(*html.LeafElNode); ok {
-				if tag := ln.Tag(); tag == "script" || tag == "style" {
-					return false, nil
+		})
+	}
+
+	for _, n := range doc.Children() {
+		switch node := n.(type) {
+		case *html.LeafElNode:
+			if tag := node.Tag(); tag == "script" || tag == "style" {
+				continue
+			}
+		case *html.ElNode:
+			if _, exists := specialTargets[node.Tag()]; exists || node.Tag() == optionsTag {
+				continue
+			}
+			if node.Tag() == headTag {
+				for _, child := range node.Children() {
+					if en, ok := child.(*html.ElNode); ok && en.Tag() == "title" {
+						continue
+					}
+					if tn, ok := child.(*html.TxtNode); ok && html.IsContentWhiteSpace(tn) {
+						continue
+					}
+
+					for _, nv := range nodeVarsFor(nt, []html.Node{child}, "") {
+						nv.inHead = !nv.hasParent
+						c.HTML = append(c.HTML, nv)
+					}
 				}
+				continue
 			}
 		}
 
-		name, err := nt.createName(n)
-		if err != nil {
-			panic(err)
-		}
+		c.HTML = append(c.HTML, nodeVarsFor(nt, []html.Node{n}, "")...)
+	}
+
+	return c, nil
+}
 
-		if parentNode, exists := ps.Parent(); exists {
-			if parentName, exists := nt.nameOf(parentNode); exists {
-				c.HTML = append(c.HTML, NewNodeVarWithParent(name, parentName, n))
+// componentName turns the name of a component file into the name of its
+// class, which is a capitalized js identifier.
+func componentName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "Component"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z_$0-9]+`)
+
+// nodeVarsFor creates the NodeVars for the nodes and all of their children,
+// the nodes are appended to the parent if a name is given for it.
+func nodeVarsFor(nt *nameTracker, nodes []html.Node, parentName string) []*NodeVar {
+	nvs := []*NodeVar{}
+	for _, n := range nodes {
+		html.Walk(n, func(n html.Node, ps html.Parents) (bool, error) {
+			name, err := nt.createName(n)
+			if err != nil {
+				panic(err)
+			}
+
+			var nv *NodeVar
+			if parentNode, exists := ps.Parent(); exists {
+				parentName, _ := nt.nameOf(parentNode)
+				nv = NewNodeVarWithParent(name, parentName, n)
+			} else if parentName != "" {
+				nv = NewNodeVarWithParent(name, parentName, n)
+			} else {
+				nv = NewNodeVar(name, n)
+			}
+			nv.namespace = namespaceOf(n, ps)
+			nvs = append(nvs, nv)
+
+			en, ok := n.(*html.ElNode)
+			if !ok {
 				return true, nil
 			}
+
+			if isComponent(en) {
+				nv.slots = slotVarsFor(nt, en)
+				return false, nil
+			}
+
+			switch en.Tag() {
+			case elementTag:
+				nv.children = nodeVarsFor(nt, en.Children(), name)
+				return false, nil
+			case slotTag:
+				nv.children = nodeVarsFor(nt, en.Children(), "")
+				return false, nil
+			}
+			return true, nil
+		})
+	}
+	return nvs
+}
+
+const (
+	svgTag           = "svg"
+	foreignObjectTag = "foreignObject"
+)
+
+// namespaceOf gets the namespace of the node from the elements it's in, a
+// <svg /> and it's content is "svg" except for the content of a
+// <foreignObject /> which is "html". It's empty when the node isn't in either,
+// so the namespace of the component is used.
+func namespaceOf(n html.Node, ps html.Parents) string {
+	if en, ok := n.(*html.ElNode); ok && en.Tag() == svgTag {
+		return "svg"
+	}
+	for i := len(ps) - 1; i >= 0; i-- {
+		en, ok := ps[i].(*html.ElNode)
+		if !ok {
+			continue
 		}
+		switch en.Tag() {
+		case svgTag:
+			return "svg"
+		case foreignObjectTag:
+			return "html"
+		}
+	}
+	return ""
+}
 
-		c.HTML = append(c.HTML, NewNodeVar(name, n))
-		return true, nil
-	})
+// slotVarsFor creates the SlotVars for the children of a component, children
+// with a slot attribute fill the named slot and the rest fill the default one.
+func slotVarsFor(nt *nameTracker, component *html.ElNode) []*SlotVar {
+	slots := []*SlotVar{}
+	dflt := &SlotVar{name: defaultSlot, lets: letsOf(component)}
+	onlyWhiteSpace := true
+	for _, n := range component.Children() {
+		if el, ok := n.(html.Element); ok {
+			slotName, exists := slotNameOf(el)
+			en, isFragment := n.(*html.ElNode)
+			isFragment = isFragment && en.Tag() == fragmentTag
 
-	return c, nil
+			switch {
+			case exists && isFragment:
+				slots = append(slots, &SlotVar{slotName, nodeVarsFor(nt, en.Children(), ""), letsOf(el)})
+				continue
+			case exists:
+				nvs := nodeVarsFor(nt, []html.Node{n}, "")
+				nvs[0].slot = slotName
+				slots = append(slots, &SlotVar{slotName, nvs, letsOf(el)})
+				continue
+			case isFragment:
+				onlyWhiteSpace = false
+				dflt.lets = append(dflt.lets, letsOf(el)...)
+				dflt.nodes = append(dflt.nodes, nodeVarsFor(nt, en.Children(), "")...)
+				continue
+			}
+		}
+
+		if tn, ok := n.(*html.TxtNode); !ok || !html.IsContentWhiteSpace(tn) {
+			onlyWhiteSpace = false
+		}
+		dflt.nodes = append(dflt.nodes, nodeVarsFor(nt, []html.Node{n}, "")...)
+	}
+
+	if onlyWhiteSpace {
+		return slots
+	}
+	return append([]*SlotVar{dflt}, slots...)
+}
+
+// A slotLet is a prop of a slot, that a let: directive makes available to the
+// content passed into the slot.
+type slotLet struct {
+	prop string
+	name string
+}
+
+// letsOf gets the slot props an element uses with let: directives.
+func letsOf(el html.Element) []slotLet {
+	lets := []slotLet{}
+	for _, attr := range el.Attrs() {
+		if dir, hasDir := attr.Dir(); hasDir && attr.Name() == "let" {
+			name := dir
+			if alias := optionValue(attr); alias != "" {
+				name = alias
+			}
+			lets = append(lets, slotLet{dir, name})
+		}
+	}
+	return lets
+}
+
+const (
+	selfTag      = "svelte:self"
+	componentTag = "svelte:component"
+	elementTag   = "svelte:element"
+	fragmentTag  = "svelte:fragment"
+	slotTag      = "slot"
+	defaultSlot  = "default"
+)
+
+// isModuleScript checks if the script has code that runs once for the module,
+// instead of for each instance of the component.
+func isModuleScript(script *html.LeafElNode) bool {
+	for _, attr := range script.Attrs() {
+		if _, hasDir := attr.Dir(); !hasDir && attr.Name() == "context" {
+			value, _ := html.StaticValue(attr)
+			return value == "module"
+		}
+	}
+	return false
+}
+
+// slotNameOf gets the name of the slot an element is passed into.
+func slotNameOf(el html.Element) (string, bool) {
+	for _, attr := range el.Attrs() {
+		if _, hasDir := attr.Dir(); !hasDir && attr.Name() == "slot" {
+			return html.StaticValue(attr)
+		}
+	}
+	return "", false
+}
+
+// thisAttrOf gets the this attribute of a <svelte:component /> or
+// <svelte:element />.
+func thisAttrOf(el html.Element) (html.Attr, bool) {
+	for _, attr := range el.Attrs() {
+		if _, hasDir := attr.Dir(); !hasDir && attr.Name() == "this" {
+			return attr, true
+		}
+	}
+	return nil, false
+}
+
+// isInsideComponent checks if the parents are of a node passed into the slot
+// of a component.
+func isInsideComponent(ps html.Parents) bool {
+	for _, p := range ps {
+		if isComponent(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isComponent checks if the node creates a child component.
+func isComponent(n html.Node) bool {
+	en, ok := n.(*html.ElNode)
+	return ok && (en.Tag() == selfTag || en.Tag() == componentTag || isComponentTag(en.Tag()))
+}
+
+// isComponentTag checks if the tag is the name of a component class, which
+// always starts with a capital letter.
+func isComponentTag(tag string) bool {
+	return tag != "" && unicode.IsUpper([]rune(tag)[0])
 }
 
 type NodeVar struct {
 	name       string
 	hasParent  bool
 	parentName string
+	inHead     bool
+	namespace  string // set by the <svg /> or <foreignObject /> it's in
+	slot       string
 	node       html.Node
+	children   []*NodeVar
+	slots      []*SlotVar
+}
+
+// A SlotVar holds the NodeVars that are passed into a slot of a component.
+type SlotVar struct {
+	name  string
+	nodes []*NodeVar
+	lets  []slotLet
 }
 
 func NewNodeVar(name string, node html.Node) *NodeVar {
@@ -119,9 +504,139 @@ func NewNodeVarWithParent(name string, parentName string, node html.Node) *NodeV
 	}
 }
 
+// specialTargets maps the special elements that listen to a global object,
+// to the js for that object.
+var specialTargets = map[string]string{
+	"svelte:window":   "window",
+	"svelte:body":     "document.body",
+	"svelte:document": "document",
+}
+
+// A TargetNode is a special element that adds listeners (and bindings) to a
+// global object instead of creating a DOM node.
+type TargetNode struct {
+	target string
+	node   *html.ElNode
+}
+
+func newTargetNode(target string, node *html.ElNode) (*TargetNode, error) {
+	for _, child := range node.Children() {
+		if tn, ok := child.(*html.TxtNode); ok && html.IsContentWhiteSpace(tn) {
+			continue
+		}
+
+		return nil, errors.New("The <" + node.Tag() + " /> element cannot have children")
+	}
+
+	return &TargetNode{
+		target: target,
+		node:   node,
+	}, nil
+}
+
+const headTag = "svelte:head"
+
+// isHeadChild checks if the parents are of a node inside <svelte:head />.
+func isHeadChild(ps html.Parents) bool {
+	if ps.Depth() < 2 {
+		return false
+	}
+
+	en, ok := ps[1].(*html.ElNode)
+	return ok && en.Tag() == headTag
+}
+
+const optionsTag = "svelte:options"
+
+// Options are the compile settings of a component, set with the attributes of
+// <svelte:options />.
+type Options struct {
+	Immutable bool
+	Accessors bool
+	Mutations bool // invalidate the vars mutating array methods are called on
+	Namespace string
+	Tag       string
+}
+
+var (
+	namespaces = map[string]string{
+		"html":                         "",
+		"svg":                          "svg",
+		"http://www.w3.org/2000/svg":   "svg",
+		"http://www.w3.org/1999/xhtml": "",
+	}
+	customElementTagRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]*)+$`)
+)
+
+func newOptions(node *html.ElNode) (Options, error) {
+	opts := Options{}
+	for _, child := range node.Children() {
+		if tn, ok := child.(*html.TxtNode); ok && html.IsContentWhiteSpace(tn) {
+			continue
+		}
+
+		return opts, errors.New("The <" + optionsTag + " /> element cannot have children")
+	}
+
+	for _, attr := range node.Attrs() {
+		if dir, hasDir := attr.Dir(); hasDir {
+			return opts, errors.New("Invaild attribute on <" + optionsTag + " />, " + attr.Name() + ":" + dir)
+		}
+
+		value := optionValue(attr)
+		switch attr.Name() {
+		case "immutable", "accessors", "mutations":
+			if value != "" && value != "true" && value != "false" {
+				return opts, errors.New("The '" + attr.Name() + "' option must be true or false")
+			}
+
+			switch attr.Name() {
+			case "immutable":
+				opts.Immutable = value != "false"
+			case "accessors":
+				opts.Accessors = value != "false"
+			default:
+				opts.Mutations = value != "false"
+			}
+		case "namespace":
+			ns, exists := namespaces[value]
+			if !exists {
+				return opts, errors.New("Unknown namespace '" + value + "' in <" + optionsTag + " />")
+			}
+			opts.Namespace = ns
+		case "tag":
+			if !customElementTagRegexp.MatchString(value) {
+				return opts, errors.New("The 'tag' option must be a lowercase name with a hyphen in it, " + value)
+			}
+			opts.Tag = value
+		default:
+			return opts, errors.New("Unknown option '" + attr.Name() + "' in <" + optionsTag + " />")
+		}
+	}
+
+	return opts, nil
+}
+
+// optionValue gets the value of an option attribute, which is either static
+// or a literal in an expression.
+func optionValue(attr html.Attr) string {
+	if value, isStatic := html.StaticValue(attr); isStatic {
+		return value
+	}
+
+	data, _ := attr.RewriteJs(js.NewVarNameRewriter(nil, nil))
+	value := strings.TrimSpace(string(data))
+	if len(value) >= 2 && strings.ContainsRune(`'"`+"`", rune(value[0])) && value[0] == value[len(value)-1] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
 type nameTracker struct {
-	pfxs  map[string]prefixData
-	names map[html.NodeId]string
+	pfxs     map[string]prefixData
+	names    map[html.NodeId]string
+	taken    map[string]bool
+	reserved map[string]bool
 }
 
 type prefixData struct {
@@ -130,12 +645,68 @@ type prefixData struct {
 }
 
 func newNameTracker() *nameTracker {
+	reserved := map[string]bool{}
+	for _, names := range [][]string{runtimeHelpers, jsReservedWords, generatedNames} {
+		for _, name := range names {
+			reserved[name] = true
+		}
+	}
+
 	return &nameTracker{
-		pfxs:  map[string]prefixData{},
-		names: map[html.NodeId]string{},
+		pfxs:     map[string]prefixData{},
+		names:    map[html.NodeId]string{},
+		taken:    map[string]bool{},
+		reserved: reserved,
+	}
+}
+
+var (
+	jsReservedWords = []string{
+		"arguments", "await", "break", "case", "catch", "class", "const", "continue",
+		"debugger", "default", "delete", "do", "else", "enum", "eval", "export",
+		"extends", "false", "finally", "for", "function", "if", "implements",
+		"import", "in", "instanceof", "interface", "let", "new", "null", "package",
+		"private", "protected", "public", "return", "static", "super", "switch",
+		"this", "throw", "true", "try", "typeof", "undefined", "var", "void",
+		"while", "with", "yield",
+	}
+	// generatedNames are the names the generated js declares or uses in the
+	// blocks, besides the names of the nodes.
+	generatedNames = []string{
+		"ctx", "dirty", "target", "anchor", "detaching", "mounted", "dispose",
+		"slots", "scrolling", "clear_scrolling", "scrolling_timeout", "title_value",
+		"instance", "window", "document", "navigator", "Object", "Array",
+	}
+	// derivedSuffixes are the suffixes of the names the generated js derives
+	// from the names of the nodes, which the names of nodes can't end with.
+	derivedSuffixes = []string{
+		"_anchor", "_binding", "_block", "_changes", "_context", "_data",
+		"_fallback", "_levels", "_previous", "_props", "_slot", "_tag",
+		"_template", "_value",
+	}
+)
+
+// reserve stops names from being created for nodes, like the names the script
+// declares.
+func (nt *nameTracker) reserve(names ...string) {
+	for _, name := range names {
+		nt.reserved[name] = true
 	}
 }
 
+// isFree reports if the name can be used for a node.
+func (nt *nameTracker) isFree(name string) bool {
+	if nt.taken[name] || nt.reserved[name] || strings.HasPrefix(name, "create_") {
+		return false
+	}
+	for _, sfx := range derivedSuffixes {
+		if strings.HasSuffix(name, sfx) {
+			return false
+		}
+	}
+	return true
+}
+
 func (nt *nameTracker) addTrackingFor(n html.Node) error {
 	if len(nt.names) != 0 {
 		return errors.New("Cannot add tracking once names have started be created")
@@ -167,16 +738,21 @@ func (nt *nameTracker) createName(n html.Node) (string, error) {
 		return "", errors.New("Cannot create name for nodes that were not tracked")
 	}
 
-	var name string
+	var base string
 	if data.total == 1 {
-		name = pfx
+		base = pfx
 	} else {
-		name = fmt.Sprintf("%s%d", pfx, data.used)
+		base = fmt.Sprintf("%s%d", pfx, data.used)
+	}
+	name := base
+	for i := 1; !nt.isFree(name); i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
 	}
 
 	data.used += 1
 	nt.pfxs[pfx] = data
 	nt.names[n.Id()] = name
+	nt.taken[name] = true
 
 	return name, nil
 }
@@ -189,7 +765,7 @@ func (nt *nameTracker) nameOf(n html.Node) (string, bool) {
 func prefixFor(n html.Node) (string, bool) {
 	switch node := n.(type) {
 	case html.Element:
-		return node.Tag(), true
+		return strings.ToLower(strings.NewReplacer("-", "_", ":", "_", ".", "_").Replace(node.Tag())), true
 	case *html.TxtNode, *html.ExprNode:
 		return "t", true
 	}
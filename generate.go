@@ -10,13 +10,39 @@ import (
 	"github.com/progrium/sveltish/internal/js"
 )
 
-func GenerateJS(c *Component) ([]byte, error) {
-	sg, err := newScriptGenerator(c)
+// GenerateJS generates the js module of the component, which imports the
+// runtime helpers it uses from the runtime of the compile options.
+func GenerateJS(c *Component, opts CompileOptions) ([]byte, error) {
+	sg, err := newScriptGenerator(c, 1)
+	if err != nil {
+		return nil, err
+	}
+	if chunks := js.DirtyChunks(len(sg.instReturns)); chunks > 1 {
+		// The number of vars is only known once the component is generated, so
+		// it is generated again with dirty arrays when there are too many.
+		sg, err = newScriptGenerator(c, chunks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sg.runtime = opts.runtime()
+	s, err := sg.Source()
 	if err != nil {
 		return nil, err
 	}
 
-	s := sg.Source()
+	if opts.RuntimeExports != nil {
+		missing := []string{}
+		for _, helper := range sg.helpers {
+			if !containsString(opts.RuntimeExports, helper) {
+				missing = append(missing, helper)
+			}
+		}
+		if len(missing) != 0 {
+			return nil, errors.New("The runtime is missing helpers, " + strings.Join(missing, ", "))
+		}
+	}
 	return s.Bytes(), nil
 }
 
@@ -24,6 +50,7 @@ type stmtType int
 
 const (
 	dec stmtType = iota
+	ini
 	set
 	mnt
 	lsn
@@ -31,224 +58,555 @@ const (
 	upd
 )
 
+// A blockGenerator holds the statements of a function that creates a block of
+// nodes, the nodes of a component are created by the create_fragment block.
+type blockGenerator struct {
+	name     string
+	dirty    string
+	stmts    map[stmtType][]string
+	depNames []string
+	depDirty js.DirtyMask
+}
+
+func newBlockGenerator(name string, dirty string) *blockGenerator {
+	return &blockGenerator{
+		name:  name,
+		dirty: dirty,
+		stmts: map[stmtType][]string{},
+	}
+}
+
+func (b *blockGenerator) insert(st stmtType, stmt string) {
+	currStmts, exists := b.stmts[st]
+	if !exists {
+		b.stmts[st] = []string{stmt}
+		return
+	}
+
+	b.stmts[st] = append(currStmts, stmt)
+}
+
+func (b *blockGenerator) insertf(st stmtType, format string, a ...interface{}) {
+	b.insert(st, fmt.Sprintf(format, a...))
+}
+
+func (b *blockGenerator) printStmts(s *js.Source, st stmtType) {
+	currStmts, exists := b.stmts[st]
+	if !exists {
+		return
+	}
+
+	for _, stmt := range currStmts {
+		s.Stmt(stmt)
+	}
+}
+
+// track adds the variables to the ones the block is updated for.
+func (b *blockGenerator) track(names []string, dirty js.DirtyMask) {
+	for _, name := range names {
+		if !containsString(b.depNames, name) {
+			b.depNames = append(b.depNames, name)
+		}
+	}
+	b.depDirty = b.depDirty.Or(dirty)
+}
+
+// mount will insert the named node into the parent of the NodeVar, or the
+// target when it is a root node.
+func (b *blockGenerator) mount(nv *NodeVar, name string) {
+	if nv.hasParent {
+		b.insertf(
+			mnt,
+			"append(%s, %s)",
+			nv.parentName,
+			name,
+		)
+	} else if nv.inHead {
+		b.insertf(
+			mnt,
+			"append(document.head, %s)",
+			name,
+		)
+		b.insertf(
+			det,
+			"detach(%s)",
+			name,
+		)
+	} else {
+		b.insertf(
+			mnt,
+			"insert(target, %s, anchor)",
+			name,
+		)
+		b.insertf(
+			det,
+			"if (detaching) detach(%s)",
+			name,
+		)
+	}
+}
+
+// mountTarget gets the node and anchor the NodeVar is mounted with.
+func mountTarget(nv *NodeVar) (string, string) {
+	if nv.hasParent {
+		return nv.parentName, "null"
+	} else if nv.inHead {
+		return "document.head", "null"
+	}
+	return "target", "anchor"
+}
+
 type scriptGenerator struct {
 	name        string
-	stmts       map[stmtType][]string
+	imports     []string
+	moduleBody  string
+	nrw         js.VarRewriter
+	arw         js.VarRewriter
+	fragment    *blockGenerator
+	blocks      []*blockGenerator
 	instBody    string
+	hoisted     string
+	scriptNames []string
+	moduleNames []string // the names the scripts declare in the module
+	helpers     []string
+	runtime     string
 	instReturns []string
+	props       []string
+	exports     []string
+	specialVars []string
+	stores      []string // the stores of props, which are subscribed to again when set
+	usesSlots   bool
+	lets        []string
+	options     Options
+	dirtyChunks int
 }
 
-func newScriptGenerator(c *Component) (*scriptGenerator, error) {
+// newScriptGenerator generates the js of the component, where the dirty var
+// of the blocks is an array when it has more than one chunk.
+func newScriptGenerator(c *Component, dirtyChunks int) (*scriptGenerator, error) {
+	fragmentDirty := "[dirty]"
+	if dirtyChunks > 1 {
+		fragmentDirty = "dirty"
+	}
+
 	sg := &scriptGenerator{
-		name:  c.Name,
-		stmts: map[stmtType][]string{},
+		name:        c.Name,
+		fragment:    newBlockGenerator("create_fragment", fragmentDirty),
+		blocks:      []*blockGenerator{},
+		props:       []string{},
+		options:     c.Options,
+		dirtyChunks: dirtyChunks,
 	}
 
-	nrw := js.NewVarNameRewriter(c.JS, func(i int, name string, _ js.Var, _ []byte) []byte {
+	if c.JS != nil {
+		c.JS.Hoist(sg.reservedName)
+		sg.scriptNames = c.JS.Names()
+	}
+
+	sg.nrw = js.NewVarNameRewriter(c.JS, func(i int, name string, _ js.Var, _ []byte) []byte {
 		return []byte(fmt.Sprintf("/* %s */ ctx[%d]", name, i))
 	})
 
-	for _, nv := range c.HTML {
-		sg.insertf(
-			dec,
-			"let %s",
-			nv.name,
+	sg.arw = js.NewAssignmentRewriter(c.JS, func(i int, _ string, _ js.Var, data []byte) []byte {
+		newData := [][]byte{}
+		newData = append(newData, []byte(fmt.Sprintf("$$invalidate(%d, ", i)))
+		newData = append(newData, data)
+		newData = append(newData, []byte(")"))
+		return bytes.Join(newData, nil)
+	})
+	if sg.options.Mutations {
+		sg.arw = js.WithMutatingCalls(sg.arw)
+	}
+
+	if c.JS != nil {
+		data, info := c.JS.RewriteForInstance(
+			sg.arw,
+			func(wrapUpds func(js.WrapUpdFn) []byte) []byte {
+				wrpData := [][]byte{}
+				wrpData = append(wrpData, []byte("\n$$self.$$.update = () => {\n"))
+				wrpData = append(
+					wrpData,
+					wrapUpds(func(labelInfo *js.VarsInfo, updData []byte) []byte {
+						return []byte(fmt.Sprintf(
+							"if (%s) {%s\n}\n",
+							sg.dirtyCheck("$$self.$$.dirty", labelInfo.Names(), labelInfo.Dirty()),
+							updData,
+						))
+					}),
+				)
+				wrpData = append(wrpData, []byte("};\n"))
+
+				return bytes.Join(wrpData, nil)
+			},
 		)
-		if nv.hasParent {
-			sg.insertf(
-				mnt,
-				"append(%s, %s)",
-				nv.parentName,
-				nv.name,
-			)
-		} else {
-			sg.insertf(
-				mnt,
-				"insert(target, %s, anchor)",
-				nv.name,
-			)
-			sg.insertf(
-				det,
-				"if (detaching) detach(%s)",
-				nv.name,
-			)
+		sg.instBody = string(data)
+		sg.hoisted = c.JS.Hoisted()
+		sg.instReturns = info.Names()
+		sg.props = c.JS.Props()
+		sg.exports = c.JS.Exports()
+		sg.specialVars = c.JS.SpecialVars()
+		sg.stores = c.JS.RebindableStores()
+	}
+
+	if c.JS != nil {
+		sg.imports = c.JS.Imports()
+		sg.moduleNames = c.JS.ImportNames()
+	}
+	if c.ModuleJS != nil {
+		sg.imports = append(sg.imports, c.ModuleJS.Imports()...)
+		sg.moduleBody = c.ModuleJS.Body()
+		sg.moduleNames = append(sg.moduleNames, c.ModuleJS.Names()...)
+	}
+
+	if err := sg.addNodes(sg.fragment, c.HTML); err != nil {
+		return sg, err
+	}
+
+	if c.Title != nil {
+		sg.addTitle(sg.fragment, c.Title)
+	}
+
+	for _, tn := range c.Targets {
+		if err := sg.addTarget(sg.fragment, tn); err != nil {
+			return sg, err
+		}
+	}
+
+	return sg, nil
+}
+
+// elementFn gets the runtime function the element of the node is created
+// with, in the namespace of the component or the <svg /> it's in.
+func (sg *scriptGenerator) elementFn(nv *NodeVar) string {
+	if sg.isSvg(nv) {
+		return "svg_element"
+	}
+	return "element"
+}
+
+// isSvg checks if the node is in the svg namespace, from the elements it's in
+// or else the namespace of the component.
+func (sg *scriptGenerator) isSvg(nv *NodeVar) bool {
+	if nv.namespace != "" {
+		return nv.namespace == "svg"
+	}
+	return sg.options.Namespace == "svg"
+}
+
+// dirtyCheck gets the js expression that checks if any of the vars of the mask
+// changed, from the dirty var of a block.
+func (sg *scriptGenerator) dirtyCheck(dirty string, names []string, mask js.DirtyMask) string {
+	comment := "/*" + strings.Join(names, " ") + "*/ "
+	if sg.dirtyChunks <= 1 {
+		return fmt.Sprintf("%s & %s%d", dirty, comment, mask.Chunk(0))
+	}
+
+	checks := []string{}
+	for i, chunk := range mask {
+		if chunk == 0 {
+			continue
+		}
+		checks = append(checks, fmt.Sprintf("%s[%d] & %s%d", dirty, i, comment, chunk))
+		comment = ""
+	}
+	if len(checks) == 1 {
+		return checks[0]
+	}
+	return "(" + strings.Join(checks, " || ") + ")"
+}
+
+// dirtyValue gets the js expression of a dirty var, that has the bits of
+// each mask set when the matching condition is true.
+func (sg *scriptGenerator) dirtyValue(conds []string, masks []js.DirtyMask) string {
+	values := []string{}
+	for c := 0; c == 0 || c < sg.dirtyChunks; c++ {
+		bits := []string{}
+		for i, mask := range masks {
+			if chunk := mask.Chunk(c); chunk != 0 {
+				bits = append(bits, fmt.Sprintf("(%s ? %d : 0)", conds[i], chunk))
+			}
+		}
+		if len(bits) == 0 {
+			bits = append(bits, "0")
+		}
+		values = append(values, strings.Join(bits, " | "))
+	}
+
+	if sg.dirtyChunks <= 1 {
+		return values[0]
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// ctxIndex gets the index in ctx of an instance variable, adding it to the
+// returns of the instance if it isn't returned yet.
+func (sg *scriptGenerator) ctxIndex(name string) int {
+	for i, returned := range sg.instReturns {
+		if returned == name {
+			return i
+		}
+	}
+
+	sg.instReturns = append(sg.instReturns, name)
+	return len(sg.instReturns) - 1
+}
+
+func (sg *scriptGenerator) addNodes(b *blockGenerator, nvs []*NodeVar) error {
+	for _, nv := range nvs {
+		if err := sg.addNode(b, nv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sg *scriptGenerator) addNode(b *blockGenerator, nv *NodeVar) error {
+	if en, ok := nv.node.(*html.ElNode); ok {
+		switch en.Tag() {
+		case selfTag:
+			return sg.addComponent(b, nv, en, sg.name)
+		case componentTag:
+			return sg.addDynamicComponent(b, nv, en)
+		case elementTag:
+			return sg.addDynamicElement(b, nv, en)
+		case slotTag:
+			return sg.addSlot(b, nv, en)
 		}
 
-		switch node := nv.node.(type) {
-		case *html.ElNode:
-			sg.insertf(
+		if isComponentTag(en.Tag()) {
+			return sg.addComponent(b, nv, en, en.Tag())
+		}
+	}
+
+	b.insertf(
+		dec,
+		"let %s",
+		nv.name,
+	)
+	b.mount(nv, nv.name)
+
+	switch node := nv.node.(type) {
+	case *html.ElNode:
+		b.insertf(
+			set,
+			`%s = %s("%s")`,
+			nv.name,
+			sg.elementFn(nv),
+			node.Tag(),
+		)
+	case *html.LeafElNode:
+		b.insertf(
+			set,
+			`%s = %s("%s")`,
+			nv.name,
+			sg.elementFn(nv),
+			node.Tag(),
+		)
+	case *html.TxtNode:
+		if html.IsContentWhiteSpace(node) {
+			b.insertf(
 				set,
-				`%s = element("%s")`,
+				"%s = space()",
 				nv.name,
-				node.Tag(),
 			)
-		case *html.LeafElNode:
-			sg.insertf(
+		} else {
+			b.insertf(
 				set,
-				`%s = element("%s")`,
+				`%s = text("%s")`,
 				nv.name,
-				node.Tag(),
+				node.Content(),
 			)
-		case *html.TxtNode:
-			if html.IsContentWhiteSpace(node) {
-				sg.insertf(
-					set,
-					"%s = space()",
-					nv.name,
-				)
-			} else {
-				sg.insertf(
-					set,
-					`%s = text("%s")`,
-					nv.name,
-					node.Content(),
-				)
-			}
-		case *html.ExprNode:
-			valContent, info := node.RewriteJs(nrw)
+		}
+	case *html.ExprNode:
+		valContent, info := node.RewriteJs(sg.nrw)
+
+		valName := fmt.Sprintf("%s_value", nv.name)
+		b.insertf(
+			dec,
+			"let %s = %s",
+			valName,
+			valContent,
+		)
+		b.insertf(
+			set,
+			"%s = text(%s)",
+			nv.name,
+			valName,
+		)
 
-			valName := fmt.Sprintf("%s_value", nv.name)
-			sg.insertf(
-				dec,
-				"let %s = %s",
+		if valDirty := info.Dirty(); !valDirty.IsZero() {
+			b.track(info.Names(), valDirty)
+			b.insertf(
+				upd,
+				"if (%s && %s !== (%s = %s)) set_data(%s, %s)",
+				sg.dirtyCheck("dirty", info.Names(), valDirty),
+				valName,
 				valName,
 				valContent,
-			)
-			sg.insertf(
-				set,
-				"%s = text(%s)",
 				nv.name,
 				valName,
 			)
-
-			if valDirty := info.Dirty(); valDirty != 0 {
-				sg.insertf(
-					upd,
-					"if (dirty & /*%s*/ %d && %s !== (%s = %s)) set_data(%s, %s)",
-					strings.Join(info.Names(), " "),
-					valDirty,
-					valName,
-					valName,
-					valContent,
-					nv.name,
-					valName,
-				)
-			}
 		}
+	}
 
-		if el, ok := nv.node.(html.Element); ok {
-			for _, attr := range el.Attrs() {
-				attContent, info := attr.RewriteJs(nrw)
+	if el, ok := nv.node.(html.Element); ok {
+		return sg.addAttrs(b, nv, el.Tag(), el.Attrs())
+	}
+	return nil
+}
 
-				dir, exists := attr.Dir()
-				if exists {
-					if name := attr.Name(); name != "on" {
-						return sg, errors.New("Invaild attribute with directive, " + name + ":" + dir)
-					}
+// addAttrs will generate the setting and updating of the attributes, and the
+// listeners, of an element.
+func (sg *scriptGenerator) addAttrs(b *blockGenerator, nv *NodeVar, tag string, attrs []html.Attr) error {
+	spreadAttrs := []html.Attr{}
+	hasSpread := false
+	for _, attr := range attrs {
+		if html.IsSpread(attr) {
+			hasSpread = true
+		}
+	}
 
-					sg.insertf(
-						lsn,
-						"listen(%s, '%s', %s)",
-						nv.name,
-						dir,
-						attContent,
-					)
-					continue
-				}
+	for _, attr := range attrs {
+		if nv.slot != "" && attr.Name() == "slot" {
+			continue
+		}
+		if _, exists := attr.Dir(); hasSpread && !exists {
+			spreadAttrs = append(spreadAttrs, attr)
+			continue
+		}
 
-				attName := fmt.Sprintf(
-					"%s_%s_value",
-					nv.name,
-					strings.ReplaceAll(attr.Name(), "-", "_"),
-				)
-				sg.insertf(
-					dec,
-					"let %s",
-					attName,
-				)
+		attContent, info := attr.RewriteJs(sg.nrw)
 
-				setAttrStmt := fmt.Sprintf(
-					"attr(%s, '%s', %s = %s)",
-					nv.name,
-					attr.Name(),
-					attName,
-					attContent,
-				)
-				sg.insert(set, setAttrStmt)
-
-				if attrDirty := info.Dirty(); attrDirty != 0 {
-					sg.insertf(
-						upd,
-						"if (dirty & /*%s*/ %d) %s",
-						strings.Join(info.Names(), " "),
-						attrDirty,
-						setAttrStmt,
-					)
+		dir, exists := attr.Dir()
+		if exists {
+			if nv.slot != "" && attr.Name() == "let" {
+				continue
+			}
+			if attr.Name() == "bind" && dir == "this" {
+				binding, err := sg.thisBinding(nv.name, attr)
+				if err != nil {
+					return err
 				}
+				b.insertf(mnt, "%s(%s)", binding, nv.name)
+				b.insertf(det, "%s(null)", binding)
+				continue
+			}
+			if name := attr.Name(); name != "on" {
+				return errors.New("Invaild attribute with directive, " + name + ":" + dir)
 			}
-		}
-	}
-
-	if c.JS == nil {
-		return sg, nil
-	}
 
-	arw := js.NewAssignmentRewriter(c.JS, func(i int, _ string, _ js.Var, data []byte) []byte {
-		newData := [][]byte{}
-		newData = append(newData, []byte(fmt.Sprintf("$$invalidate(%d, ", i)))
-		newData = append(newData, data)
-		newData = append(newData, []byte(")"))
-		return bytes.Join(newData, nil)
-	})
-	data, info := c.JS.RewriteForInstance(
-		arw,
-		func(wrapUpds func(js.WrapUpdFn) []byte) []byte {
-			wrpData := [][]byte{}
-			wrpData = append(wrpData, []byte("\n$$self.$$.update = () => {\n"))
-			wrpData = append(
-				wrpData,
-				wrapUpds(func(labelInfo *js.VarsInfo, updData []byte) []byte {
-					return []byte(fmt.Sprintf(
-						"if ($$self.$$.dirty & /*%s*/ %d) {%s\n}\n",
-						strings.Join(labelInfo.Names(), " "),
-						labelInfo.Dirty(),
-						updData,
-					))
-				}),
+			b.insertf(
+				lsn,
+				"listen(%s, '%s', %s)",
+				nv.name,
+				dir,
+				sg.eventHandler(dir, attr),
 			)
-			wrpData = append(wrpData, []byte("};\n"))
+			continue
+		}
 
-			return bytes.Join(wrpData, nil)
-		},
-	)
-	sg.instBody = string(data)
-	sg.instReturns = info.Names()
+		if attr.IsStatic() && isBooleanAttr(attr.Name()) {
+			attContent = []byte("true")
+		}
 
-	return sg, nil
-}
+		attName := fmt.Sprintf(
+			"%s_%s_value",
+			nv.name,
+			strings.NewReplacer("-", "_", ":", "_").Replace(attr.Name()),
+		)
+		b.insertf(
+			dec,
+			"let %s",
+			attName,
+		)
 
-func (sg *scriptGenerator) insert(st stmtType, stmt string) {
-	currStmts, exists := sg.stmts[st]
-	if !exists {
-		sg.stmts[st] = []string{stmt}
-		return
+		setAttrStmt := attrSetter(
+			nv.name,
+			tag,
+			attr.Name(),
+			fmt.Sprintf("%s = %s", attName, attContent),
+		)
+		b.insert(set, setAttrStmt)
+
+		if attrDirty := info.Dirty(); !attrDirty.IsZero() {
+			b.track(info.Names(), attrDirty)
+			b.insertf(
+				upd,
+				"if (%s) %s",
+				sg.dirtyCheck("dirty", info.Names(), attrDirty),
+				setAttrStmt,
+			)
+		}
 	}
 
-	sg.stmts[st] = append(currStmts, stmt)
+	if hasSpread {
+		sg.addSpreadAttrs(b, nv, spreadAttrs)
+	}
+	return nil
 }
 
-func (sg *scriptGenerator) insertf(st stmtType, format string, a ...interface{}) {
-	sg.insert(st, fmt.Sprintf(format, a...))
-}
+// addSpreadAttrs sets the attributes of an element that has spread attributes,
+// where each attribute is a level of the object of attributes and the later
+// levels override the earlier ones.
+func (sg *scriptGenerator) addSpreadAttrs(b *blockGenerator, nv *NodeVar, attrs []html.Attr) {
+	levels := []string{}
+	updates := []string{}
+	allNames := []string{}
+	allDirty := js.DirtyMask{}
+	for i, attr := range attrs {
+		attContent, info := attr.RewriteJs(sg.nrw)
+
+		level := string(attContent)
+		if !html.IsSpread(attr) {
+			if attr.IsStatic() && isBooleanAttr(attr.Name()) {
+				attContent = []byte("true")
+			}
+			level = fmt.Sprintf("{ %s: %s }", propKey(attr.Name()), attContent)
+		}
+		levels = append(levels, level)
 
-func (sg *scriptGenerator) printStmts(s *js.Source, st stmtType) {
-	currStmts, exists := sg.stmts[st]
-	if !exists {
-		return
+		attrDirty := info.Dirty()
+		if attrDirty.IsZero() {
+			updates = append(updates, fmt.Sprintf("%s_levels[%d]", nv.name, i))
+			continue
+		}
+		updates = append(updates, fmt.Sprintf(
+			"%s && %s",
+			sg.dirtyCheck("dirty", info.Names(), attrDirty),
+			level,
+		))
+		allNames = append(allNames, info.Names()...)
+		allDirty = allDirty.Or(attrDirty)
 	}
 
-	for _, stmt := range currStmts {
-		s.Stmt(stmt)
+	setAttrsFn := "set_attributes"
+	if sg.isSvg(nv) {
+		setAttrsFn = "set_svg_attributes"
+	}
+
+	b.insertf(dec, "let %s_levels = [%s]", nv.name, strings.Join(levels, ", "))
+	b.insertf(dec, "let %s_data = {}", nv.name)
+	b.insertf(
+		ini,
+		"for (let i = 0; i < %s_levels.length; i += 1) %s_data = assign(%s_data, %s_levels[i])",
+		nv.name,
+		nv.name,
+		nv.name,
+		nv.name,
+	)
+	b.insertf(set, "%s(%s, %s_data)", setAttrsFn, nv.name, nv.name)
+
+	if !allDirty.IsZero() {
+		b.track(allNames, allDirty)
+		b.insertf(
+			upd,
+			"%s(%s, %s_data = get_spread_update(%s_levels, [%s]))",
+			setAttrsFn,
+			nv.name,
+			nv.name,
+			nv.name,
+			strings.Join(updates, ", "),
+		)
 	}
 }
 
@@ -257,48 +615,107 @@ func (sg *scriptGenerator) hasInst() bool {
 }
 
 func (sg *scriptGenerator) printInst(s *js.Source) {
+	if sg.usesSlots {
+		s.Stmt("let { $$slots: slots = {}, $$scope } = $$props")
+	}
 	s.Line(sg.instBody)
+
+	usesProps := containsString(sg.specialVars, "$$props")
+	usesRestProps := containsString(sg.specialVars, "$$restProps")
+
+	setProps := sg.props
+	if sg.usesSlots {
+		setProps = append(setProps[:len(setProps):len(setProps)], "$$scope")
+	}
+	if len(setProps) != 0 || usesProps || usesRestProps {
+		newProps := "$$props"
+		if usesProps || usesRestProps {
+			newProps = "$$new_props"
+		}
+
+		s.Stmt(fmt.Sprintf("$$self.$$set = %s =>", newProps), func(s *js.Source) {
+			if usesProps || usesRestProps {
+				propsStmt := "$$props = assign(assign({}, $$props), exclude_internal_props($$new_props))"
+				if usesProps {
+					propsStmt = fmt.Sprintf("$$invalidate(%d, %s)", sg.ctxIndex("$$props"), propsStmt)
+				}
+				s.Stmt(propsStmt)
+			}
+			if usesRestProps {
+				s.Stmt(fmt.Sprintf(
+					"$$invalidate(%d, $$restProps = compute_rest_props($$props, omit_props_names))",
+					sg.ctxIndex("$$restProps"),
+				))
+			}
+			for _, prop := range setProps {
+				set := fmt.Sprintf("$$invalidate(%d, %s = %s.%s)", sg.ctxIndex(prop), prop, newProps, prop)
+				if containsString(sg.stores, prop) {
+					set = fmt.Sprintf("$$subscribe_%s(%s)", prop, set)
+				}
+				s.Stmt(fmt.Sprintf("if ('%s' in %s) %s", prop, newProps, set))
+			}
+		}, ";")
+	}
+	if usesProps {
+		s.Stmt("$$props = exclude_internal_props($$props)")
+	}
+
+	returns := []string{}
+	for _, name := range sg.instReturns {
+		if containsString(sg.lets, name) {
+			returns = append(returns, "undefined")
+			continue
+		}
+		returns = append(returns, name)
+	}
 	s.Stmt(fmt.Sprintf(
 		"return [%s]",
-		strings.Join(sg.instReturns, ", "),
+		strings.Join(returns, ", "),
 	))
 }
 
-func (sg *scriptGenerator) Source() *js.Source {
-	s := &js.Source{}
-	s.Stmt(`import {
-  SvelteComponent,
-  append,
-  detach,
-  element,
-  text,
-  space,
-  attr,
-  listen,
-  init,
-  insert,
-  noop,
-  safe_not_equal,
-  set_data,
-  run_all
-} from`, s.Str("./runtime"))
-	s.Line("")
-	s.Func("create_fragment", []string{"ctx"}, func(s *js.Source) {
-		sg.printStmts(s, dec)
+// printAccessors prints the getters and setters of the props, for the class
+// of the component.
+func (sg *scriptGenerator) printAccessors(s *js.Source) {
+	for _, prop := range sg.props {
+		s.Stmt(fmt.Sprintf("get %s()", prop), func(s *js.Source) {
+			s.Stmt(fmt.Sprintf("return this.$$.ctx[%d]", sg.ctxIndex(prop)))
+		})
+		s.Stmt(fmt.Sprintf("set %s(%s)", prop, prop), func(s *js.Source) {
+			s.Stmt(fmt.Sprintf("this.$$set({ %s })", prop))
+			s.Stmt("flush()")
+		})
+	}
+}
+
+// printExports prints the getters of the exported consts and functions, for
+// the class of the component.
+func (sg *scriptGenerator) printExports(s *js.Source) {
+	for _, name := range sg.exports {
+		s.Stmt(fmt.Sprintf("get %s()", name), func(s *js.Source) {
+			s.Stmt(fmt.Sprintf("return this.$$.ctx[%d]", sg.ctxIndex(name)))
+		})
+	}
+}
+
+func (sg *scriptGenerator) printBlock(s *js.Source, b *blockGenerator) {
+	s.Func(b.name, []string{"ctx"}, func(s *js.Source) {
+		b.printStmts(s, dec)
 		s.Stmt("let mounted")
 		s.Stmt("let dispose")
+		b.printStmts(s, ini)
 
 		s.Line("")
 		s.Stmt("return", func(s *js.Source) {
 			s.Stmt("c()", func(s *js.Source) {
-				sg.printStmts(s, set)
+				b.printStmts(s, set)
 			}, ",")
 			s.Stmt("m(target, anchor)", func(s *js.Source) {
-				sg.printStmts(s, mnt)
+				b.printStmts(s, mnt)
 
 				s.Stmt("if(!mounted)", func(s *js.Source) {
 					s.Line("dispose = [")
-					if lsnStmts, exists := sg.stmts[lsn]; exists {
+					if lsnStmts, exists := b.stmts[lsn]; exists {
 						for _, lsnStmt := range lsnStmts {
 							s.Line("  " + lsnStmt + ",")
 						}
@@ -308,38 +725,269 @@ func (sg *scriptGenerator) Source() *js.Source {
 					s.Stmt("mounted = true")
 				})
 			}, ",")
-			s.Stmt("p(ctx, [dirty])", func(s *js.Source) {
-				sg.printStmts(s, upd)
+			s.Stmt(fmt.Sprintf("p(ctx, %s)", b.dirty), func(s *js.Source) {
+				b.printStmts(s, upd)
 			}, ",")
 			s.Line("i: noop,")
 			s.Line("o: noop,")
 			s.Stmt("d(detaching)", func(s *js.Source) {
-				sg.printStmts(s, det)
+				b.printStmts(s, det)
 				s.Line("")
 				s.Stmt("mounted = false")
 				s.Stmt("run_all(dispose)")
 			}, ",")
 		}, ";")
 	})
+}
+
+// runtimeHelpers are the functions of the runtime the generated js can
+// import, only the ones it uses are imported.
+var runtimeHelpers = []string{
+	"SvelteComponent",
+	"SvelteElement",
+	"attribute_to_object",
+	"assign",
+	"exclude_internal_props",
+	"compute_rest_props",
+	"compute_slots",
+	"flush",
+	"append",
+	"detach",
+	"element",
+	"svg_element",
+	"text",
+	"space",
+	"empty",
+	"attr",
+	"set_input_value",
+	"set_custom_element_data",
+	"set_attributes",
+	"set_svg_attributes",
+	"get_spread_update",
+	"xlink_attr",
+	"listen",
+	"subscribe",
+	"component_subscribe",
+	"set_store_value",
+	"add_render_callback",
+	"binding_callbacks",
+	"create_component",
+	"mount_component",
+	"destroy_component",
+	"create_slot",
+	"update_slot_base",
+	"get_slot_changes",
+	"init",
+	"insert",
+	"noop",
+	"safe_not_equal",
+	"not_equal",
+	"set_data",
+	"run_all",
+	"transition_in",
+	"transition_out",
+	"group_outros",
+	"check_outros",
+	"destroy_each",
+	"destroy_block",
+	"outro_and_destroy_block",
+	"update_keyed_each",
+	"handle_promise",
+	"update_await_block_branch",
+	"set_style",
+	"toggle_class",
+	"prevent_default",
+	"stop_propagation",
+	"action_destroyer",
+	"is_function",
+	"select_option",
+	"select_value",
+	"to_number",
+	"set_input_type",
+	"get_spread_object",
+	"null_to_empty",
+	"add_resize_listener",
+	"add_flush_callback",
+}
+
+// reservedName reports if the name is declared in the module by the
+// generated js, which is the runtime imports, the block functions, the
+// instance function and the component class.
+func (sg *scriptGenerator) reservedName(name string) bool {
+	return containsString(runtimeHelpers, name) ||
+		strings.HasPrefix(name, "create_") ||
+		name == "instance" ||
+		name == sg.name
+}
+
+func (sg *scriptGenerator) Source() (*js.Source, error) {
+	if err := sg.checkModuleNames(); err != nil {
+		return nil, err
+	}
+
+	body := sg.body()
+	helpers, err := usedHelpers(body)
+	if err != nil {
+		return nil, err
+	}
+	sg.helpers = helpers
+
+	s := &js.Source{}
+	s.Stmt("import {\n  "+strings.Join(helpers, ",\n  ")+"\n} from", s.Str(sg.runtime))
+	s.Line(body.String())
+
+	return s, nil
+}
+
+// checkModuleNames makes sure the scripts don't declare the names in the
+// module that the generated js imports from the runtime or declares, which
+// would resolve to the declarations of the scripts or be declared twice.
+func (sg *scriptGenerator) checkModuleNames() error {
+	declared := []string{sg.fragment.name}
+	for _, b := range sg.blocks {
+		declared = append(declared, b.name)
+	}
+	if sg.hasInst() {
+		declared = append(declared, "instance")
+	}
+
+	for _, name := range sg.moduleNames {
+		if containsString(runtimeHelpers, name) {
+			return errors.New("The name is reserved for the runtime helpers, " + name)
+		}
+		if containsString(declared, name) {
+			return errors.New("The name is declared by the generated js, " + name)
+		}
+	}
+	return nil
+}
+
+// usedHelpers finds the runtime helpers the generated js references, in the
+// order of runtimeHelpers.
+func usedHelpers(body *js.Source) ([]string, error) {
+	names, err := js.FreeNames(body.Bytes())
+	if err != nil {
+		return nil, errors.New("Invalid generated js, " + err.Error())
+	}
+
+	helpers := []string{}
+	for _, helper := range runtimeHelpers {
+		if containsString(names, helper) {
+			helpers = append(helpers, helper)
+		}
+	}
+	return helpers, nil
+}
+
+// body generates the js of the module after the runtime import.
+func (sg *scriptGenerator) body() *js.Source {
+	s := &js.Source{}
+	for _, imp := range sg.imports {
+		s.Line(strings.TrimSpace(imp))
+	}
 	s.Line("")
+	if sg.moduleBody != "" {
+		s.Line(sg.moduleBody)
+		s.Line("")
+	}
+	if sg.hoisted != "" {
+		s.Line(sg.hoisted)
+		s.Line("")
+	}
+	for _, b := range sg.blocks {
+		sg.printBlock(s, b)
+		s.Line("")
+	}
+	sg.printBlock(s, sg.fragment)
+	s.Line("")
+
+	instance := "null"
 	if sg.hasInst() {
+		instance = "instance"
 		s.Func("instance", []string{"$$self", "$$props", "$$invalidate"}, func(s *js.Source) {
 			sg.printInst(s)
 		})
 	}
+
+	props := "{}"
+	if len(sg.props) != 0 || len(sg.exports) != 0 {
+		propIndexes := []string{}
+		for _, prop := range append(sg.props[:len(sg.props):len(sg.props)], sg.exports...) {
+			propIndexes = append(propIndexes, fmt.Sprintf("%s: %d", prop, sg.ctxIndex(prop)))
+		}
+		props = "{ " + strings.Join(propIndexes, ", ") + " }"
+	}
+
+	notEqual := "safe_not_equal"
+	if sg.options.Immutable {
+		notEqual = "not_equal"
+	}
+	if sg.dirtyChunks > 1 {
+		chunks := []string{}
+		for i := 0; i < sg.dirtyChunks; i++ {
+			chunks = append(chunks, "-1")
+		}
+		props += ", null, [" + strings.Join(chunks, ", ") + "]"
+	}
+
 	s.Line("")
-	s.Stmt("class", sg.name, "extends SvelteComponent", func(s *js.Source) {
-		s.Stmt("constructor(options)", func(s *js.Source) {
-			s.Stmt("super()")
-			s.Stmt("init(this, options, instance, create_fragment, safe_not_equal, {})")
+	if sg.options.Tag == "" {
+		s.Stmt("class", sg.name, "extends SvelteComponent", func(s *js.Source) {
+			s.Stmt("constructor(options)", func(s *js.Source) {
+				s.Stmt("super()")
+				s.Stmt(fmt.Sprintf(
+					"init(this, options, %s, create_fragment, %s, %s)",
+					instance,
+					notEqual,
+					props,
+				))
+			})
+			if sg.options.Accessors {
+				sg.printAccessors(s)
+			}
+			sg.printExports(s)
 		})
-	})
+	} else {
+		s.Stmt("class", sg.name, "extends SvelteElement", func(s *js.Source) {
+			s.Stmt("constructor(options)", func(s *js.Source) {
+				s.Stmt("super()")
+				s.Stmt(fmt.Sprintf(
+					"init(this, { target: this.shadowRoot, props: attribute_to_object(this.attributes), customElement: true }, %s, create_fragment, %s, %s)",
+					instance,
+					notEqual,
+					props,
+				))
+				s.Stmt("if (options)", func(s *js.Source) {
+					s.Stmt("if (options.target) insert(options.target, this, options.anchor)")
+					s.Stmt("if (options.props)", func(s *js.Source) {
+						s.Stmt("this.$set(options.props)")
+						s.Stmt("flush()")
+					})
+				})
+			})
+			s.Stmt("static get observedAttributes()", func(s *js.Source) {
+				attrs := []string{}
+				for _, prop := range sg.props {
+					attrs = append(attrs, s.Str(prop))
+				}
+				s.Stmt(fmt.Sprintf("return [%s]", strings.Join(attrs, ", ")))
+			})
+			sg.printAccessors(s)
+			sg.printExports(s)
+		})
+		s.Stmt(fmt.Sprintf("customElements.define(%s, %s)", s.Str(sg.options.Tag), sg.name))
+	}
 	s.Line("")
 	s.Stmt("export default", sg.name)
 
-	//
-	fmt.Println(s.String())
-	//*/
-
 	return s
 }
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
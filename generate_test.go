@@ -0,0 +1,461 @@
+package sveltish
+
+import (
+	"strings"
+	"testing"
+)
+
+// generateTestJS compiles the component and returns the generated js.
+func generateTestJS(t *testing.T, src string, opts CompileOptions) string {
+	t.Helper()
+	c, err := Parse("Test", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse returned error: %q", err.Error())
+	}
+	data, err := GenerateJS(c, opts)
+	if err != nil {
+		t.Fatalf("GenerateJS returned error: %q", err.Error())
+	}
+	return string(data)
+}
+
+// expectJS fails the test for each of the snippets the js doesn't contain.
+func expectJS(t *testing.T, js string, snippets ...string) {
+	t.Helper()
+	for _, snippet := range snippets {
+		if !strings.Contains(js, snippet) {
+			t.Errorf("Expected generated js to contain %q, got:\n%s", snippet, js)
+		}
+	}
+}
+
+// rejectJS fails the test for each of the snippets the js contains.
+func rejectJS(t *testing.T, js string, snippets ...string) {
+	t.Helper()
+	for _, snippet := range snippets {
+		if strings.Contains(js, snippet) {
+			t.Errorf("Expected generated js not to contain %q, got:\n%s", snippet, js)
+		}
+	}
+}
+
+func TestGenerateWindowHandlerNames(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	let y = 0, w = 0;
+	function onwindowscroll() { return 1; }
+	function onwindowresize() { return 2; }
+	function onwindowresize_1() { return 3; }
+</script>
+<svelte:window bind:scrollY={y} bind:innerWidth={w} on:click={onwindowscroll} />
+<p>{y} {w} {onwindowresize()} {onwindowresize_1()}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		"function onwindowscroll() { return 1; }",
+		"function onwindowresize() { return 2; }",
+		"function onwindowresize_1() { return 3; }",
+		"function onwindowscroll_1() {",
+		"function onwindowresize_2() {",
+		"listen(window, 'resize', /* onwindowresize_2 */ ctx[",
+	)
+	rejectJS(t, js, "function onwindowscroll() {\n")
+}
+
+func TestGenerateRuntimeExports(t *testing.T) {
+	src := `<script>let count = 0;</script><button on:click="{() => count += 1}">{count}</button>`
+	c, err := Parse("Test", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse returned error: %q", err.Error())
+	}
+
+	js := generateTestJS(t, src, CompileOptions{})
+	expectJS(t, js, "import {\n  SvelteComponent,", "  listen,\n", "} from \"sveltish/runtime\"")
+	rejectJS(t, js, "  mount_component,")
+
+	_, err = GenerateJS(c, CompileOptions{RuntimeExports: []string{"SvelteComponent", "init", "safe_not_equal"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "The runtime is missing helpers, ") || !strings.Contains(err.Error(), "listen") {
+		t.Fatalf("Expected a missing helpers error, got %v", err)
+	}
+}
+
+func TestGenerateInlineHandlers(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import { writable } from "svelte/store";
+	let count = 0;
+	const total = writable(0);
+	function log() {}
+</script>
+<button on:click="{() => count++}" on:focus="{log}">{count}</button>
+<button on:click="{() => $total++}">{$total}</button>`, CompileOptions{})
+
+	expectJS(t, js,
+		"const click_handler = () => $$invalidate(",
+		"const click_handler_1 = () => set_store_value(total,",
+		"'click', /* click_handler */ ctx[",
+		"'click', /* click_handler_1 */ ctx[",
+		"listen(button0, 'focus', log)",
+	)
+	rejectJS(t, js, "focus_handler")
+}
+
+func TestGenerateSlotHandlers(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import List from "./List.elem";
+	let picked = null;
+</script>
+<List let:item on:select="{() => picked = 1}"><button on:click="{() => picked = item}">{item}</button></List>
+<p>{picked}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		"list.$on('select', /* select_handler */ ctx[1]);",
+		"const select_handler = () => $$invalidate(0, picked = 1);",
+		"const click_handler = (item) => () => $$invalidate(0, picked = item);",
+		"listen(button, 'click', function (...args) { return /* click_handler */ ctx[3](/* item */ ctx[2]).apply(this, args); })",
+	)
+}
+
+func TestGenerateStoreWrites(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import { writable } from "svelte/store";
+	const name = writable("");
+	const y = writable(0);
+	const el = writable(null);
+</script>
+<svelte:window bind:scrollY={$y} />
+<input bind:this={$el} on:input="{() => $name = $name.trim()}">
+<p>{$name} {$y}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		"const input_handler = () => set_store_value(name, $name = $name.trim(), $name);",
+		"listen(input, 'input', /* input_handler */ ctx[",
+		"set_store_value(el, $el = $$value, $el);",
+		"set_store_value(y, $y = window.pageYOffset, $y);",
+	)
+}
+
+func TestGenerateStoreRebinding(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import { writable } from "svelte/store";
+	export let store = writable(0);
+	const other = writable(1);
+	let local = store;
+	function swap() { local = other; }
+</script>
+<button on:click={swap}>{$store} {$local} {$other}</button>`, CompileOptions{})
+
+	expectJS(t, js,
+		"let $local, $$unsubscribe_local = noop, $$subscribe_local = () => ($$unsubscribe_local(), $$unsubscribe_local = subscribe(local, value => $$invalidate(",
+		"$$self.$$.on_destroy.push(() => $$unsubscribe_local());",
+		"$$subscribe_local();",
+		"function swap() { $$subscribe_local(local = other); }",
+		"let $store, $$unsubscribe_store = noop, $$subscribe_store = () => (",
+		"$$subscribe_store();",
+		"if ('store' in $$props) $$subscribe_store($$invalidate(",
+		"component_subscribe($$self, other, value => $$invalidate(",
+	)
+	rejectJS(t, js,
+		"component_subscribe($$self, local,",
+		"component_subscribe($$self, store,",
+	)
+}
+
+func TestGenerateTitle(t *testing.T) {
+	js := generateTestJS(t, `<script>let name = "world";</script>
+<svelte:head><title>C:\temp `+"`"+`{name}`+"`"+`</title></svelte:head>
+<p>{name}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		`document.title = title_value = `+"`"+`C:\\temp \`+"`"+`${/* name */ ctx[0]}\`+"``",
+		`if (dirty & /*name*/ 1 && title_value !== (title_value = `+"`"+`C:\\temp `,
+	)
+}
+
+func TestGenerateOptions(t *testing.T) {
+	js := generateTestJS(t, `<svelte:options immutable accessors />
+<script>export let name = "a";</script>
+<p>{name}</p>`, CompileOptions{})
+	expectJS(t, js,
+		"init(this, options, instance, create_fragment, not_equal, { name: 0 });",
+		"  get name() {\n    return this.$$.ctx[0];\n  }\n",
+		"  set name(name) {\n    this.$$set({ name });\n    flush();\n  }\n",
+	)
+
+	js = generateTestJS(t, `<script>export let name = "a";</script>
+<p>{name}</p>`, CompileOptions{})
+	expectJS(t, js, "init(this, options, instance, create_fragment, safe_not_equal, { name: 0 });")
+	rejectJS(t, js, "get name()", "set name(name)")
+
+	js = generateTestJS(t, `<svelte:options namespace="svg" />
+<script>export let r = 1;</script>
+<circle r={r} />`, CompileOptions{})
+	expectJS(t, js, "  svg_element,\n", `circle = svg_element("circle");`)
+	rejectJS(t, js, "  element,\n")
+
+	js = generateTestJS(t, `<svelte:options tag="my-el" />
+<script>export let name = "a";</script>
+<p>{name}</p>`, CompileOptions{})
+	expectJS(t, js,
+		"class Test extends SvelteElement {",
+		"init(this, { target: this.shadowRoot, props: attribute_to_object(this.attributes), customElement: true }, instance, create_fragment, safe_not_equal, { name: 0 });",
+		"  static get observedAttributes() {\n    return [\"name\"];\n  }\n",
+		"  get name() {\n    return this.$$.ctx[0];\n  }\n",
+		"  set name(name) {\n    this.$$set({ name });\n    flush();\n  }\n",
+		`customElements.define("my-el", Test);`,
+	)
+}
+
+func TestGenerateModuleScript(t *testing.T) {
+	js := generateTestJS(t, `<script context="module">
+	let total = 0;
+	export function reset() { total = 0; }
+	const MAX = 10;
+</script>
+<script>
+	let count = 0;
+	total += 1;
+</script>
+<p>{count} {total} {MAX}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		"export function reset() { total = 0; }",
+		"let t4_value = total;",
+		"let t6_value = MAX;",
+		"\ttotal += 1;\n",
+		"return [count];",
+	)
+	rejectJS(t, js, "/* total */", "/* MAX */")
+	if strings.Index(js, "let total = 0;") > strings.Index(js, "function create_fragment(ctx)") {
+		t.Errorf("Expected the module script before the fragment, got:\n%s", js)
+	}
+}
+
+func TestGenerateRuntime(t *testing.T) {
+	src := `<script>let count = 0;</script><p>{count}</p>`
+	expectJS(t, generateTestJS(t, src, CompileOptions{}), `} from "sveltish/runtime";`)
+
+	js := generateTestJS(t, src, CompileOptions{Runtime: "./lib/runtime.js"})
+	expectJS(t, js, `} from "./lib/runtime.js";`)
+	rejectJS(t, js, `"sveltish/runtime"`)
+}
+
+func TestGenerateSvg(t *testing.T) {
+	js := generateTestJS(t, `<script>let name = "a"; let href = "#a"; let attrs = {};</script>
+<svg viewBox="0 0 10 10"><use xlink:href={href}/><text x="1">Hi {name}</text><rect {...attrs} /></svg>
+<p>{name}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		`svg = svg_element("svg");`,
+		`use = svg_element("use");`,
+		"xlink_attr(use, 'xlink:href', use_xlink_href_value = /* href */ ctx[1]);",
+		"if (dirty & /*href*/ 2) xlink_attr(use, 'xlink:href',",
+		`text_1 = svg_element("text");`,
+		`t1 = text("Hi ");`,
+		"set_svg_attributes(rect, rect_data);",
+		`p = element("p");`,
+	)
+}
+
+func TestGenerateDynamicElements(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import A from "./A.elem";
+	export let depth = 0;
+	let comp = A;
+	let tag = "div";
+</script>
+<svelte:component this={comp} depth={depth} />
+<svelte:element this={tag} class="x">{depth}</svelte:element>
+<A><svelte:self depth="{depth + 1}" /></A>`, CompileOptions{})
+
+	expectJS(t, js,
+		"let svelte_component_value = /* comp */ ctx[1];",
+		"if (svelte_component_value) { svelte_component = new svelte_component_value(svelte_component_props(ctx)) };",
+		"if (dirty & /*comp*/ 2 && svelte_component_value !== (svelte_component_value = /* comp */ ctx[1])) { if (svelte_component) destroy_component(svelte_component, 1);",
+		"svelte_element = element(/* tag */ ctx[2]);",
+		"let svelte_element_block = svelte_element_tag && create_svelte_element_block(ctx);",
+		"} else if (safe_not_equal(svelte_element_tag_previous, svelte_element_tag)) { svelte_element_block.d(1);",
+		"let svelte_self = new Test({ props: { depth: /* depth */ ctx[0] + 1 } });",
+		"if (dirty & /*depth*/ 1) svelte_self_changes.depth = /* depth */ ctx[0] + 1;",
+	)
+
+	c, err := Parse("Test", strings.NewReader(`<svelte:self />`))
+	if err == nil {
+		_, err = GenerateJS(c, CompileOptions{})
+	}
+	if err == nil || err.Error() != "<svelte:self /> can only be used inside the slots passed to a component" {
+		t.Fatalf("Expected an error for <svelte:self /> outside of a slot, got %v", err)
+	}
+}
+
+func TestGenerateSlotFragments(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import List from "./List.elem";
+	let title = "T";
+</script>
+<List let:item={entry}>
+	<svelte:fragment slot="header" let:count>{title} {count}</svelte:fragment>
+	<p>{entry}</p>
+</List>`, CompileOptions{})
+
+	expectJS(t, js,
+		"default: [create_list_default_slot, ({ item: entry }) => ({ 1: entry }), ({ item: entry }) => (entry ? 2 : 0)]",
+		"header: [create_list_header_slot, ({ count }) => ({ 2: count }), ({ count }) => (count ? 4 : 0)]",
+		"let t6_value = /* entry */ ctx[1];",
+		"insert(target, t2, anchor);",
+		"if (dirty & /*count*/ 4 && t4_value !== (t4_value = /* count */ ctx[2])) set_data(t4, t4_value);",
+		"if (dirty & /*title*/ 1) list_changes.$$scope = { dirty, ctx };",
+		"return [title, undefined, undefined];",
+	)
+	rejectJS(t, js, "svelte_fragment")
+}
+
+func TestGenerateExportsAndThisBindings(t *testing.T) {
+	js := generateTestJS(t, `<script>
+	import Child from "./Child.elem";
+	export const version = "1";
+	export function focus() { input.focus(); }
+	export let label = "";
+	let input;
+	let child;
+</script>
+<input bind:this={input}>
+<Child bind:this={child} />
+<p>{label}</p>`, CompileOptions{})
+
+	expectJS(t, js,
+		"init(this, options, instance, create_fragment, safe_not_equal, { label: 2, version: 0, focus: 1 });",
+		"  get version() {\n    return this.$$.ctx[0];\n  }\n",
+		"  get focus() {\n    return this.$$.ctx[1];\n  }\n",
+		"/* input_1_binding */ ctx[5](input_1);",
+		"/* input_1_binding */ ctx[5](null);",
+		"$$invalidate(3, input = $$value);",
+		"/* child_1_binding */ ctx[6](child_1);",
+		"$$invalidate(4, child = $$value);",
+		"return [version, focus, label, input, child, input_1_binding, child_1_binding];",
+	)
+	rejectJS(t, js, "set version(", "set focus(")
+
+	c, err := Parse("Test", strings.NewReader(`<script>let els = {};</script><input bind:this={els.input}>`))
+	if err == nil {
+		_, err = GenerateJS(c, CompileOptions{})
+	}
+	if err == nil || err.Error() != "Can only bind to a root variable, bind:this" {
+		t.Fatalf("Expected an error binding to a member, got %v", err)
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+	testData := []struct {
+		file      string
+		className string
+	}{
+		{"fancy-button", "Fancy_button"},
+		{"2col", "_2col"},
+		{"button", "Button_1"},
+		{"--", "Component"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.file, func(t *testing.T) {
+			c, err := Parse(td.file, strings.NewReader(`<script>let p = 1; let Button = 2;</script>
+<p>{p} {Button}</p><my-value />`))
+			if err != nil {
+				t.Fatalf("Parse returned error: %q", err.Error())
+			}
+			data, err := GenerateJS(c, CompileOptions{})
+			if err != nil {
+				t.Fatalf("GenerateJS returned error: %q", err.Error())
+			}
+
+			js := string(data)
+			expectJS(t, js,
+				"class "+td.className+" extends SvelteComponent {",
+				"export default "+td.className+";",
+				`p_1 = element("p");`,
+				`my_value_1 = element("my-value");`,
+			)
+			rejectJS(t, js, "let p;", "let my_value;")
+		})
+	}
+}
+
+func TestGenerateForeignObject(t *testing.T) {
+	js := generateTestJS(t, `<svg><foreignObject width="10"><div><span>Hi</span></div></foreignObject><g /></svg>`, CompileOptions{})
+	expectJS(t, js,
+		`foreignobject = svg_element("foreignObject");`,
+		`div = element("div");`,
+		`span = element("span");`,
+		`g = svg_element("g");`,
+	)
+	rejectJS(t, js, `svg_element("div")`, `svg_element("span")`)
+
+	js = generateTestJS(t, `<svelte:options namespace="svg" />
+<foreignObject><div /></foreignObject><circle />`, CompileOptions{})
+	expectJS(t, js,
+		`foreignobject = svg_element("foreignObject");`,
+		`div = element("div");`,
+		`circle = svg_element("circle");`,
+	)
+}
+
+func TestGenerateUnquotedExprs(t *testing.T) {
+	js := generateTestJS(t, `<script>let a = 4, b = 2, count = 0;</script>
+<p title={a / b}>{count}</p>
+<button disabled={count > 2} on:click={() => count++}>+</button>`, CompileOptions{})
+
+	expectJS(t, js,
+		"attr(p, 'title', p_title_value = /* a */ ctx[0] / /* b */ ctx[1]);",
+		"button.disabled = button_disabled_value = /* count */ ctx[2] > 2;",
+		"const click_handler = () => $$invalidate(2, count++, count);",
+		"listen(button, 'click', /* click_handler */ ctx[",
+	)
+}
+
+func TestGenerateReservedModuleNames(t *testing.T) {
+	testData := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{
+			"ImportedHelper",
+			`<script>import { element } from "./utils.js";</script><p>{element()}</p>`,
+			"The name is reserved for the runtime helpers, element",
+		},
+		{
+			"ModuleHelper",
+			`<script context="module">function listen() {}</script>
+<script>let count = 0;</script>
+<button on:click={() => count++}>{count}</button>`,
+			"The name is reserved for the runtime helpers, listen",
+		},
+		{
+			"ModuleInstance",
+			`<script context="module">export function instance() { return 1; }</script>
+<script>let a = 1;</script>
+<p>{a}</p>`,
+			"The name is declared by the generated js, instance",
+		},
+		{
+			"ModuleBlock",
+			`<script context="module">const create_fragment = null;</script><p>a</p>`,
+			"The name is declared by the generated js, create_fragment",
+		},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			c, err := Parse("Test", strings.NewReader(td.src))
+			if err == nil {
+				_, err = GenerateJS(c, CompileOptions{})
+			}
+			if err == nil || err.Error() != td.err {
+				t.Fatalf("Expected error %q, got %v", td.err, err)
+			}
+		})
+	}
+
+	js := generateTestJS(t, `<script context="module">function create_each_block() {}</script>
+<p>{create_each_block()}</p>`, CompileOptions{})
+	expectJS(t, js, "function create_each_block() {}", "function create_fragment(ctx) {")
+}
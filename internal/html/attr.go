@@ -13,10 +13,20 @@ import (
 type Attr interface {
 	Name() string
 	Dir() (string, bool)
+	IsStatic() bool
 	RewriteJs(rw js.VarRewriter) ([]byte, *js.VarsInfo)
 }
 
 func newAttr(data []byte) (Attr, error) {
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{...")) {
+		if !bytes.HasSuffix(trimmed, []byte("}")) {
+			return nil, errors.New("Unclosed spread attribute")
+		}
+		return &spreadAttr{
+			expr: string(trimmed[4 : len(trimmed)-1]),
+		}, nil
+	}
+
 	prts := bytes.SplitN(data, []byte("="), 2)
 
 	at := newAttrType(stripInitWhiteSpace(prts[0]))
@@ -98,6 +108,9 @@ func stripInitWhiteSpace(data []byte) []byte {
 }
 
 func stripQuotes(data []byte) []byte {
+	if len(data) == 0 || (data[0] != '"' && data[0] != '\'') {
+		return data
+	}
 	if len(data) <= 2 {
 		return []byte{}
 	}
@@ -111,8 +124,20 @@ type attrType struct {
 	hasDir bool
 }
 
+// namespacePrefixes are the attribute name prefixes that are part of the
+// attribute name rather than a directive.
+var namespacePrefixes = []string{"xlink", "xml", "xmlns"}
+
 func newAttrType(data []byte) *attrType {
 	prts := bytes.SplitN(data, []byte(":"), 2)
+	if len(prts) == 2 {
+		for _, ns := range namespacePrefixes {
+			if string(prts[0]) == ns {
+				prts = [][]byte{data}
+				break
+			}
+		}
+	}
 	if len(prts) == 1 {
 		return &attrType{
 			name:   string(prts[0]),
@@ -141,16 +166,32 @@ type staticAttr struct {
 	content string
 }
 
+func (attr *staticAttr) IsStatic() bool {
+	return true
+}
+
 func (attr *staticAttr) RewriteJs(_ js.VarRewriter) ([]byte, *js.VarsInfo) {
 	data := []byte("'" + strings.ReplaceAll(attr.content, "'", `\'`) + "'")
 	return data, js.NewEmptyVarsInfo()
 }
 
+// StaticValue gets the content of an attribute that has no expressions.
+func StaticValue(a Attr) (string, bool) {
+	if attr, ok := a.(*staticAttr); ok {
+		return attr.content, true
+	}
+	return "", false
+}
+
 type exprAttr struct {
 	attrType
 	expr string
 }
 
+func (attr *exprAttr) IsStatic() bool {
+	return false
+}
+
 func (attr *exprAttr) RewriteJs(rw js.VarRewriter) ([]byte, *js.VarsInfo) {
 	return rw.Rewrite([]byte(attr.expr))
 }
@@ -161,6 +202,10 @@ type tmplAttr struct {
 	exprs []string
 }
 
+func (attr *tmplAttr) IsStatic() bool {
+	return false
+}
+
 func (attr *tmplAttr) RewriteJs(rw js.VarRewriter) ([]byte, *js.VarsInfo) {
 	data := [][]byte{}
 	data = append(data, []byte("`"))
@@ -180,3 +225,23 @@ func (attr *tmplAttr) RewriteJs(rw js.VarRewriter) ([]byte, *js.VarsInfo) {
 	data = append(data, []byte("`"))
 	return bytes.Join(data, nil), js.MergeVarsInfo(allInfo...)
 }
+
+type spreadAttr struct {
+	attrType
+	expr string
+}
+
+func (attr *spreadAttr) IsStatic() bool {
+	return false
+}
+
+func (attr *spreadAttr) RewriteJs(rw js.VarRewriter) ([]byte, *js.VarsInfo) {
+	return rw.Rewrite([]byte(attr.expr))
+}
+
+// IsSpread checks if the attribute spreads an object into the attributes, as
+// in {...props}.
+func IsSpread(a Attr) bool {
+	_, ok := a.(*spreadAttr)
+	return ok
+}
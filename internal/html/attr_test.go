@@ -95,6 +95,14 @@ func TestNewAttr(t *testing.T) {
 			"innerText",
 			"'Some Text'",
 		},
+		{
+			"NamespacedName",
+			[]byte(`xlink:href="#icon"`),
+			"static",
+			"xlink:href",
+			"",
+			"'#icon'",
+		},
 		{
 			"ExprWithJustAnExpr",
 			[]byte(`value="{testValue}"`),
@@ -111,6 +119,14 @@ func TestNewAttr(t *testing.T) {
 			"",
 			"testValue === `some ${value}`",
 		},
+		{
+			"ExprWithoutQuotes",
+			[]byte(`disabled={isDisabled}`),
+			"expr",
+			"disabled",
+			"",
+			"isDisabled",
+		},
 		{
 			"ExprWithHyphenInName",
 			[]byte(`data-value="{testValue}"`),
@@ -199,6 +215,14 @@ func TestNewAttr(t *testing.T) {
 			"innerText",
 			"`Some ${text}`",
 		},
+		{
+			"Spread",
+			[]byte(`{...$$restProps}`),
+			"spread",
+			"",
+			"",
+			"$$restProps",
+		},
 	}
 
 	for _, td := range testData {
@@ -218,6 +242,10 @@ func TestNewAttr(t *testing.T) {
 				if td.attrType != "tmpl" {
 					t.Fatalf("Attr should be %q, but a tmplAttr was created", td.attrType)
 				}
+			case *spreadAttr:
+				if td.attrType != "spread" {
+					t.Fatalf("Attr should be %q, but a spreadAttr was created", td.attrType)
+				}
 			}
 
 			if attr.Name() != td.attrName {
@@ -59,6 +59,9 @@ func TestIndexAfterExpr(t *testing.T) {
 		{"ArrayLookup", []byte("{varName[100]}")},
 		{"ObjectValue", []byte("{varName.value}")},
 		{"FuctionCall", []byte("{func(100)}")},
+		{"Division", []byte("{total / count}")},
+		{"RegexWithBrace", []byte("{value.replace(/}/g, '')}")},
+		{"NestedTemplateStrings", []byte("{`a ${`b ${value}}`}`}")},
 		{"MethodCall", []byte("{varName.func(100)}")},
 		{"FuctionCallWithString", []byte("{func('test string')}")},
 		{"FuctionCallWithComplexString", []byte("{func('test string with } and `')}")},
@@ -1,15 +1,25 @@
 package html
 
 import (
+	"bytes"
 	"io"
+	"strings"
 
-	"github.com/tdewolff/parse/v2"
+	"github.com/progrium/sveltish/internal/js"
 	"github.com/tdewolff/parse/v2/html"
 )
 
+// A lexer splits the source of a template into the tokens of the html lexer.
+// It reads the source itself, so tag and attribute names keep the case they
+// were written in and the elements of <svg /> are lexed like any other.
 type lexer struct {
-	lex   *html.Lexer
-	stack []lexerOutput
+	src    []byte
+	pos    int
+	inTag  bool
+	tag    string
+	rawTag string
+	stack  []lexerOutput
+	err    error
 }
 
 type lexerOutput struct {
@@ -18,28 +28,225 @@ type lexerOutput struct {
 }
 
 func newLexer(src io.Reader) *lexer {
+	data, err := io.ReadAll(src)
+
 	return &lexer{
-		html.NewLexer(parse.NewInput(src)),
-		[]lexerOutput{},
+		src:   data,
+		stack: []lexerOutput{},
+		err:   err,
 	}
 }
 
+// rawTextTags are the tags with content that is text, even if it looks like
+// elements.
+var rawTextTags = []string{"script", "style", "textarea", "title", "xmp", "iframe"}
+
 func (lex *lexer) rewind(tt html.TokenType, data []byte) {
 	lex.stack = append(lex.stack, lexerOutput{tt, data})
 }
 
 func (lex *lexer) Next() (html.TokenType, []byte) {
 	stackSize := len(lex.stack)
-	if stackSize == 0 {
-		return lex.lex.Next()
+	if stackSize != 0 {
+		info := lex.stack[stackSize-1]
+		lex.stack = lex.stack[:stackSize-1]
+
+		return info.tt, info.data
+	}
+
+	if lex.err != nil {
+		return html.ErrorToken, nil
+	}
+	if lex.inTag {
+		return lex.nextInTag()
+	}
+	if lex.rawTag != "" {
+		end := lex.indexEndTag(lex.rawTag)
+		lex.rawTag = ""
+		if end > lex.pos {
+			return html.TextToken, lex.shift(end)
+		}
+	}
+	if lex.pos >= len(lex.src) {
+		lex.err = io.EOF
+		return html.ErrorToken, nil
+	}
+
+	src := lex.src[lex.pos:]
+	switch {
+	case len(src) > 1 && src[0] == '<' && isLetter(src[1]):
+		end := lex.pos + 1
+		for end < len(lex.src) && !isSpace(lex.src[end]) && !lex.at(end, ">") && !lex.at(end, "/>") {
+			end++
+		}
+		lex.inTag = true
+		lex.tag = strings.ToLower(string(lex.src[lex.pos+1 : end]))
+		return html.StartTagToken, lex.shift(end)
+	case len(src) > 2 && bytes.HasPrefix(src, []byte("</")) && isLetter(src[2]):
+		return html.EndTagToken, lex.shift(lex.indexAfter(lex.pos, ">"))
+	case bytes.HasPrefix(src, []byte("<!--")):
+		return html.CommentToken, lex.shift(lex.indexAfter(lex.pos+4, "-->"))
+	case len(src) > 8 && bytes.EqualFold(src[:9], []byte("<!doctype")):
+		return html.DoctypeToken, lex.shift(lex.indexAfter(lex.pos, ">"))
+	case bytes.HasPrefix(src, []byte("<!")), bytes.HasPrefix(src, []byte("<?")):
+		return html.CommentToken, lex.shift(lex.indexAfter(lex.pos, ">"))
+	}
+
+	end := lex.pos + 1
+	for end < len(lex.src) && !lex.startsMarkup(end) {
+		end++
+	}
+	return html.TextToken, lex.shift(end)
+}
+
+// nextInTag lexes the attributes of a start tag and the end of it.
+func (lex *lexer) nextInTag() (html.TokenType, []byte) {
+	start := lex.pos
+	for start < len(lex.src) && isSpace(lex.src[start]) {
+		start++
+	}
+
+	switch {
+	case start >= len(lex.src):
+		lex.pos = start
+		lex.err = io.EOF
+		return html.ErrorToken, nil
+	case lex.at(start, ">"):
+		lex.pos = start
+		lex.inTag = false
+		if containsTag(rawTextTags, lex.tag) {
+			lex.rawTag = lex.tag
+		}
+		return html.StartTagCloseToken, lex.shift(start + 1)
+	case lex.at(start, "/>"):
+		lex.pos = start
+		lex.inTag = false
+		return html.StartTagVoidToken, lex.shift(start + 2)
+	}
+
+	return html.AttributeToken, lex.shift(lex.indexAfterAttr(start))
+}
+
+// indexAfterAttr finds the end of the attribute that starts at the index,
+// which is the name and the value it is set to. The expressions in them are
+// skipped as a whole, so they can have spaces and > in them.
+func (lex *lexer) indexAfterAttr(start int) int {
+	end := start
+	for end < len(lex.src) && !isSpace(lex.src[end]) && lex.src[end] != '=' && !lex.atTagEnd(end) {
+		end = lex.indexAfterChar(end)
+	}
+
+	i := lex.skipSpace(end)
+	if !lex.at(i, "=") {
+		return end
+	}
+
+	i = lex.skipSpace(i + 1)
+	if i < len(lex.src) && (lex.src[i] == '"' || lex.src[i] == '\'') {
+		quote := lex.src[i]
+		for i++; i < len(lex.src) && lex.src[i] != quote; {
+			i = lex.indexAfterChar(i)
+		}
+		if i < len(lex.src) {
+			i++
+		}
+		return i
+	}
+
+	for i < len(lex.src) && !isSpace(lex.src[i]) && !lex.atTagEnd(i) {
+		i = lex.indexAfterChar(i)
 	}
+	return i
+}
+
+// indexAfterChar finds the index after the char at the index, or after the
+// expression when one starts there and is closed.
+func (lex *lexer) indexAfterChar(i int) int {
+	if lex.src[i] == '{' {
+		if end := js.IndexAfterCurlyGroup(lex.src[i:]); end != -1 {
+			return i + end
+		}
+	}
+	return i + 1
+}
+
+// indexEndTag finds where the end tag of the raw text element starts, which
+// is the end of the source when it isn't closed.
+func (lex *lexer) indexEndTag(tag string) int {
+	for i := lex.pos; i < len(lex.src); i++ {
+		end := i + 2 + len(tag)
+		if !lex.at(i, "</") || end > len(lex.src) || !strings.EqualFold(string(lex.src[i+2:end]), tag) {
+			continue
+		}
+		if end == len(lex.src) || !isLetter(lex.src[end]) {
+			return i
+		}
+	}
+	return len(lex.src)
+}
 
-	info := lex.stack[stackSize-1]
-	lex.stack = lex.stack[:stackSize-1]
+// startsMarkup checks if a tag, end tag or comment starts at the index, which
+// ends the text before it.
+func (lex *lexer) startsMarkup(i int) bool {
+	if lex.src[i] != '<' || i+1 >= len(lex.src) {
+		return false
+	}
+
+	next := lex.src[i+1]
+	return isLetter(next) || next == '!' || next == '?' || (next == '/' && i+2 < len(lex.src) && isLetter(lex.src[i+2]))
+}
 
-	return info.tt, info.data
+// indexAfter finds the index after the first match of the string from the
+// index, which is the end of the source when there is no match.
+func (lex *lexer) indexAfter(start int, str string) int {
+	index := bytes.Index(lex.src[start:], []byte(str))
+	if index == -1 {
+		return len(lex.src)
+	}
+	return start + index + len(str)
+}
+
+func (lex *lexer) skipSpace(i int) int {
+	for i < len(lex.src) && isSpace(lex.src[i]) {
+		i++
+	}
+	return i
+}
+
+// atTagEnd checks if the start tag is closed at the index.
+func (lex *lexer) atTagEnd(i int) bool {
+	return lex.at(i, ">") || lex.at(i, "/>")
+}
+
+func (lex *lexer) at(i int, str string) bool {
+	return i < len(lex.src) && bytes.HasPrefix(lex.src[i:], []byte(str))
+}
+
+// shift gives the source from the position to the index, and moves the
+// position to the index.
+func (lex *lexer) shift(end int) []byte {
+	data := lex.src[lex.pos:end]
+	lex.pos = end
+	return data
 }
 
 func (lex *lexer) Err() error {
-	return lex.lex.Err()
+	return lex.err
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
 }
@@ -54,9 +54,13 @@ func (n *ElNode) parse(idg *idGenerator, lex *lexer) error {
 	}
 	n.tag = string(data[1:])
 
-	if err := parseAttr(n, lex); err != nil {
+	selfClosing, err := parseAttr(n, lex)
+	if err != nil {
 		return err
 	}
+	if selfClosing || isVoidTag(n.tag) {
+		return nil
+	}
 
 	tt, data = lex.Next()
 	for tt != html.EndTagToken {
@@ -74,21 +78,18 @@ func (n *LeafElNode) parse(idg *idGenerator, lex *lexer) error {
 	n.id = idg.next()
 
 	tt, data := lex.Next()
-	switch tt {
-	case html.SvgToken:
-		return errors.New("NYI: parsing <svg />")
-	case html.MathToken:
-		return errors.New("NYI: parsing <math />")
-	case html.StartTagToken:
-		n.tag = string(data[1:])
-	default:
+	if tt != html.StartTagToken {
 		return errors.New("Invalid parser position passed to leafElNode.parse")
 	}
+	n.tag = string(data[1:])
 
-	err := parseAttr(n, lex)
+	selfClosing, err := parseAttr(n, lex)
 	if err != nil {
 		return err
 	}
+	if selfClosing {
+		return nil
+	}
 
 	tt, data = lex.Next()
 	if tt == html.TextToken {
@@ -162,17 +163,6 @@ func parseNextChild(n mutableContainer, idg *idGenerator, lex *lexer) error {
 			return err
 		}
 
-		n.appendChild(newNode)
-		return nil
-	case html.SvgToken:
-	case html.MathToken:
-		newNode := &LeafElNode{}
-
-		lex.rewind(tt, data)
-		if err := newNode.parse(idg, lex); err != nil {
-			return err
-		}
-
 		n.appendChild(newNode)
 		return nil
 	case html.TextToken:
@@ -202,20 +192,33 @@ func parseNextChild(n mutableContainer, idg *idGenerator, lex *lexer) error {
 	return errors.New("invalid token in children")
 }
 
-func parseAttr(n mutableElement, lex *lexer) error {
+// parseAttr will add all the attributes of the current start tag to the
+// element, and reports if the tag was closed with "/>".
+func parseAttr(n mutableElement, lex *lexer) (bool, error) {
 	tt, data := lex.Next()
-	for tt != html.StartTagCloseToken {
+	for tt != html.StartTagCloseToken && tt != html.StartTagVoidToken {
 		if tt != html.AttributeToken {
-			return errors.New("Invalid token when attribute expected")
+			return false, errors.New("Invalid token when attribute expected")
 		}
 
 		attr, err := newAttr(data)
 		if err != nil {
-			return err
+			return false, err
 		}
 		n.appendAttr(attr)
 
 		tt, data = lex.Next()
 	}
-	return nil
+	return tt == html.StartTagVoidToken, nil
+}
+
+// isVoidTag checks if the tag is a html void element, which never has
+// children or an end tag.
+func isVoidTag(tag string) bool {
+	switch tag {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr":
+		return true
+	}
+
+	return false
 }
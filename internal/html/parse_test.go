@@ -0,0 +1,109 @@
+package html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseElements(t *testing.T) {
+	testData := []struct {
+		name  string
+		input string
+		tags  []string
+		attrs []string
+	}{
+		{
+			"VoidElement",
+			`<div><input value="test"><br></div>`,
+			[]string{"div", "input", "br"},
+			[]string{"value"},
+		},
+		{
+			"SelfClosingElement",
+			`<svelte:window on:keydown="{handle}" /><p></p>`,
+			[]string{"svelte:window", "p"},
+			[]string{"on:keydown"},
+		},
+		{
+			"SelfClosingAfterExpr",
+			`<svelte:window bind:scrollY={y}/><p></p>`,
+			[]string{"svelte:window", "p"},
+			[]string{"bind:scrollY"},
+		},
+		{
+			"UnquotedExprWithSpaces",
+			`<p title={a / b} class:big={count > 2}>x</p><button on:click={() => count++} disabled={count > 2}/>`,
+			[]string{"p", "button"},
+			[]string{"title", "class:big", "on:click", "disabled"},
+		},
+		{
+			"QuotedExprWithQuotes",
+			`<button on:click="{() => alert("a > b")}" title='{a + "'"}'></button>`,
+			[]string{"button"},
+			[]string{"on:click", "title"},
+		},
+		{
+			"KeepsCase",
+			`<Nested onChange="{handle}"></Nested>`,
+			[]string{"Nested"},
+			[]string{"onChange"},
+		},
+		{
+			"KeepsCaseAfterSkippedSpace",
+			`<Nested onChange="{handle}"  ><Inner Foo="x" /><!-- a --></Nested  ><Last onClick={go}/>`,
+			[]string{"Nested", "Inner", "Last"},
+			[]string{"onChange", "Foo", "onClick"},
+		},
+		{
+			"SvgElements",
+			`<svg viewBox="0 0 10 10"><use xlink:href="#a"/><text x={x}>Hi {name}</text></svg><p></p>`,
+			[]string{"svg", "use", "text", "p"},
+			[]string{"viewBox", "xlink:href", "x"},
+		},
+		{
+			"NestedSvgElements",
+			`<SVG><g><svg width="2"><circle r="1"/></svg></g><rect /></SVG>`,
+			[]string{"SVG", "g", "svg", "circle", "rect"},
+			[]string{"width", "r"},
+		},
+		{
+			"RawTextElements",
+			`<title>a <b>c</b></title><script>if (a<b) {}</script><p></p>`,
+			[]string{"title", "script", "p"},
+			[]string{},
+		},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(td.input))
+			if err != nil {
+				t.Fatalf("Parse return error: %q", err.Error())
+			}
+
+			tags := []string{}
+			attrs := []string{}
+			Walk(doc, func(n Node, _ Parents) (bool, error) {
+				if el, ok := n.(Element); ok {
+					tags = append(tags, el.Tag())
+					for _, attr := range el.Attrs() {
+						name := attr.Name()
+						if dir, exists := attr.Dir(); exists {
+							name += ":" + dir
+						}
+						attrs = append(attrs, name)
+					}
+				}
+				return true, nil
+			})
+
+			if strings.Join(tags, " ") != strings.Join(td.tags, " ") {
+				t.Fatalf("Expected tags %q but got %q", td.tags, tags)
+			}
+			if strings.Join(attrs, " ") != strings.Join(td.attrs, " ") {
+				t.Fatalf("Expected attributes %q but got %q", td.attrs, attrs)
+			}
+		})
+	}
+}
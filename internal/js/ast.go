@@ -0,0 +1,360 @@
+package js
+
+// An astNode is a node of the parsed js, which knows the byte offsets of the
+// source it was parsed from.
+type astNode interface {
+	pos() span
+}
+
+// A span is the start and end byte offsets of a node in the source.
+type span struct {
+	start, end int
+}
+
+func (s span) pos() span {
+	return s
+}
+
+// A program is the list of statements of a parsed script or snippet.
+type program struct {
+	span
+	body []astNode
+}
+
+// Expressions
+
+type identExpr struct {
+	span
+	name string
+}
+
+// A literalExpr is any expression that doesn't reference a variable, like
+// strings, numbers, regexps, this and super.
+type literalExpr struct {
+	span
+	raw string
+}
+
+type templateExpr struct {
+	span
+	tag   astNode
+	exprs []astNode
+}
+
+type arrayExpr struct {
+	span
+	elems []astNode // nil for holes
+}
+
+// A spreadExpr is a spread element or argument, or a rest element of a
+// pattern.
+type spreadExpr struct {
+	span
+	arg astNode
+}
+
+type objectExpr struct {
+	span
+	props []*property
+}
+
+// A property is a property of an object literal or pattern, a spread property
+// has no key and a spreadExpr value.
+type property struct {
+	span
+	key       astNode
+	computed  bool
+	value     astNode
+	shorthand bool
+	method    bool
+}
+
+type funcExpr struct {
+	span
+	name      *identExpr
+	params    []astNode
+	body      astNode // a *blockStmt, or an expression for arrow functions
+	arrow     bool
+	async     bool
+	generator bool
+	decl      bool
+}
+
+type classExpr struct {
+	span
+	name    *identExpr
+	super   astNode
+	members []*classMember
+	decl    bool
+}
+
+// A classMember is a method or field of a class, static blocks have no key
+// and a *blockStmt value.
+type classMember struct {
+	span
+	key      astNode
+	computed bool
+	static   bool
+	value    astNode
+}
+
+type unaryExpr struct {
+	span
+	op  string
+	arg astNode
+}
+
+type updateExpr struct {
+	span
+	op     string
+	prefix bool
+	arg    astNode
+}
+
+type binaryExpr struct {
+	span
+	op          string
+	left, right astNode
+}
+
+type assignExpr struct {
+	span
+	op     string
+	target astNode
+	value  astNode
+}
+
+type condExpr struct {
+	span
+	test, cons, alt astNode
+}
+
+type callExpr struct {
+	span
+	callee   astNode
+	args     []astNode
+	optional bool
+	isNew    bool
+}
+
+type memberExpr struct {
+	span
+	object   astNode
+	property astNode
+	computed bool
+	optional bool
+}
+
+type seqExpr struct {
+	span
+	exprs []astNode
+}
+
+type parenExpr struct {
+	span
+	expr astNode
+}
+
+// Statements
+
+type varDecl struct {
+	span
+	kind  string
+	decls []*declarator
+}
+
+type declarator struct {
+	span
+	target astNode
+	init   astNode
+}
+
+type exprStmt struct {
+	span
+	expr astNode
+}
+
+type blockStmt struct {
+	span
+	body []astNode
+}
+
+type emptyStmt struct {
+	span
+}
+
+type ifStmt struct {
+	span
+	test, cons, alt astNode
+}
+
+type forStmt struct {
+	span
+	init, test, update astNode
+	body               astNode
+}
+
+type forInStmt struct {
+	span
+	left, right astNode
+	body        astNode
+	of          bool
+}
+
+type whileStmt struct {
+	span
+	test, body astNode
+	do         bool
+}
+
+// A jumpStmt is a return, throw, break, continue or debugger statement.
+type jumpStmt struct {
+	span
+	keyword string
+	label   *identExpr
+	arg     astNode
+}
+
+type labeledStmt struct {
+	span
+	label *identExpr
+	body  astNode
+}
+
+type switchStmt struct {
+	span
+	disc  astNode
+	cases []*switchCase
+}
+
+type switchCase struct {
+	span
+	test astNode // nil for the default case
+	body []astNode
+}
+
+type tryStmt struct {
+	span
+	block     *blockStmt
+	param     astNode
+	handler   *blockStmt
+	finalizer *blockStmt
+}
+
+type withStmt struct {
+	span
+	object, body astNode
+}
+
+type importDecl struct {
+	span
+	specs  []*moduleSpec
+	source string
+}
+
+type exportDecl struct {
+	span
+	decl   astNode // a declaration, or the expression of a default export
+	dflt   bool
+	specs  []*moduleSpec
+	source string
+	all    bool
+}
+
+// A moduleSpec is a specifier of an import or export declaration, the local
+// is the binding in this module and the name the one in the other module.
+type moduleSpec struct {
+	span
+	local *identExpr
+	name  string
+}
+
+// unparen returns the expression inside any parentheses.
+func unparen(n astNode) astNode {
+	for {
+		paren, ok := n.(*parenExpr)
+		if !ok {
+			return n
+		}
+		n = paren.expr
+	}
+}
+
+// bindingNames returns the identifiers a pattern binds or assigns.
+func bindingNames(n astNode) []*identExpr {
+	switch n := n.(type) {
+	case *identExpr:
+		return []*identExpr{n}
+	case *parenExpr:
+		return bindingNames(n.expr)
+	case *assignExpr:
+		return bindingNames(n.target)
+	case *spreadExpr:
+		return bindingNames(n.arg)
+	case *arrayExpr:
+		names := []*identExpr{}
+		for _, e := range n.elems {
+			if e != nil {
+				names = append(names, bindingNames(e)...)
+			}
+		}
+		return names
+	case *objectExpr:
+		names := []*identExpr{}
+		for _, p := range n.props {
+			names = append(names, bindingNames(p.value)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// assignedNames returns the identifiers of the vars an assignment target
+// changes, which for member expressions is the object they're a member of.
+func assignedNames(n astNode) []*identExpr {
+	switch n := n.(type) {
+	case *identExpr:
+		return []*identExpr{n}
+	case *memberExpr:
+		if root := memberRoot(n); root != nil {
+			return []*identExpr{root}
+		}
+	case *parenExpr:
+		return assignedNames(n.expr)
+	case *assignExpr:
+		return assignedNames(n.target)
+	case *spreadExpr:
+		return assignedNames(n.arg)
+	case *arrayExpr:
+		names := []*identExpr{}
+		for _, e := range n.elems {
+			if e != nil {
+				names = append(names, assignedNames(e)...)
+			}
+		}
+		return names
+	case *objectExpr:
+		names := []*identExpr{}
+		for _, p := range n.props {
+			names = append(names, assignedNames(p.value)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// memberRoot gets the identifier a chain of member expressions starts with,
+// it's nil when the chain starts with something else, like a call or this.
+func memberRoot(n *memberExpr) *identExpr {
+	var obj astNode = n
+	for {
+		switch o := unparen(obj).(type) {
+		case *memberExpr:
+			obj = o.object
+		case *identExpr:
+			return o
+		default:
+			return nil
+		}
+	}
+}
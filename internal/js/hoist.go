@@ -0,0 +1,116 @@
+package js
+
+import "strings"
+
+// Hoist finds the root consts with literal values, and the root functions
+// that don't use the state of the instance, which are moved out of the
+// instance to the module. They don't get a slot in ctx and the template uses
+// them directly. Names that are reserved in the module are never hoisted.
+func (n *Script) Hoist(reserved func(string) bool) {
+	n.hoisted = map[Node]bool{}
+	if n.prog == nil {
+		return
+	}
+
+	a := analyzeScopes(n.prog)
+	assigned := map[string]bool{}
+	for _, as := range a.assigns {
+		for _, ident := range assignedNames(as.expr.target) {
+			if a.resolvesToRoot(as.scope, ident.name) {
+				assigned[ident.name] = true
+			}
+		}
+	}
+	for _, up := range a.updates {
+		for _, ident := range assignedNames(up.expr.arg) {
+			if a.resolvesToRoot(up.scope, ident.name) {
+				assigned[ident.name] = true
+			}
+		}
+	}
+
+	// the state of the instance is every root var that isn't hoisted
+	state := map[string]bool{}
+	for _, v := range n.rootVars() {
+		for _, name := range v.VarNames() {
+			state[name] = true
+		}
+	}
+	hoistable := func(v Var) bool {
+		for _, name := range v.VarNames() {
+			if reserved(name) || assigned[name] {
+				return false
+			}
+		}
+		return true
+	}
+	hoist := func(v Var) {
+		n.hoisted[v] = true
+		for _, name := range v.VarNames() {
+			delete(state, name)
+		}
+	}
+
+	for _, r := range n.roots {
+		if vn, ok := r.(*VarNode); ok && vn.isLiteralConst() && hoistable(vn) {
+			hoist(vn)
+		}
+	}
+
+	// functions can call the functions that are hoisted before them, so they
+	// are checked again until no more functions are hoisted
+	for changed := true; changed; {
+		changed = false
+		for _, r := range n.roots {
+			fn, ok := r.(*FuncNode)
+			if !ok || n.hoisted[fn] || !hoistable(fn) || fn.usesState(state) {
+				continue
+			}
+			hoist(fn)
+			changed = true
+		}
+	}
+}
+
+// Hoisted returns the js of the consts and functions that are hoisted to the
+// module.
+func (n *Script) Hoisted() string {
+	data := []string{}
+	for _, r := range n.roots {
+		if n.hoisted[r] {
+			data = append(data, r.Js())
+		}
+	}
+	return strings.Join(data, "")
+}
+
+// isLiteralConst reports if the node is a const declaration that only has
+// literal values.
+func (n *VarNode) isLiteralConst() bool {
+	if n.decl.kind != "const" {
+		return false
+	}
+
+	for _, d := range n.decl.decls {
+		if _, ok := d.target.(*identExpr); !ok {
+			return false
+		}
+		lit, ok := d.init.(*literalExpr)
+		if !ok || lit.raw == "this" || lit.raw == "super" {
+			return false
+		}
+	}
+	return true
+}
+
+// usesState reports if the function references any of the state vars, other
+// than itself.
+func (n *FuncNode) usesState(state map[string]bool) bool {
+	a := analyzeScopes(&program{n.fn.span, []astNode{n.fn}})
+	for _, r := range a.rootRefs() {
+		if name := r.ident.name; name != n.fn.name.name && state[name] {
+			return true
+		}
+	}
+	return false
+}
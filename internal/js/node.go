@@ -3,7 +3,6 @@ package js
 import (
 	"bytes"
 	"strings"
-	"unicode"
 )
 
 // All node types implement the Node interface.
@@ -20,7 +19,10 @@ type Var interface {
 
 // A Script node represents a full js script tag.
 type Script struct {
-	roots []Node
+	roots   []Node
+	prog    *program
+	used    map[string]bool // the root vars the template references
+	hoisted map[Node]bool   // the roots that are moved to the module
 }
 
 type rewriteAssignmenter interface {
@@ -36,36 +38,65 @@ func (n *Script) RewriteForInstance(
 	wrapUpds WrapUpdsFn,
 ) ([]byte, *VarsInfo) {
 	nrmlRoots := []Node{}
-	ratvRoots := []*LabelNode{}
-	for _, n := range n.roots {
-		if ln, ok := n.(*LabelNode); ok && ln.IsReactive() {
-			ratvRoots = append(ratvRoots, ln)
+	for _, r := range n.roots {
+		if ln, ok := r.(*LabelNode); (ok && ln.IsReactive()) || n.hoisted[r] {
 			continue
 		}
 
-		nrmlRoots = append(nrmlRoots, n)
+		nrmlRoots = append(nrmlRoots, r)
 	}
+	// the order was checked for cycles when the script was parsed
+	ratvRoots, _ := n.sortReactive()
 
 	info := NewEmptyVarsInfo()
-	i := 0
-	for _, v := range n.rootVars() {
-		for _, name := range v.VarNames() {
-			info.insert(i, name)
-			i += 1
+	for i, cv := range n.ctxVars() {
+		info.insert(i, cv.name)
+	}
+
+	// reactive assignments only declare the vars that aren't declared yet
+	declared := map[string]bool{}
+	for _, r := range nrmlRoots {
+		if v, ok := r.(Var); ok {
+			for _, name := range v.VarNames() {
+				declared[name] = true
+			}
 		}
 	}
 
 	data := [][]byte{}
 	for _, r := range ratvRoots {
-		if len(r.name) == 0 {
-			continue
+		for _, name := range r.VarNames() {
+			if declared[name] {
+				continue
+			}
+			declared[name] = true
+			data = append(data, []byte("\nlet "+name+";"))
+		}
+	}
+	for _, r := range nrmlRoots {
+		switch v := r.(type) {
+		case *StoreVar:
+			data = append(data, v.declareForInstance(rw))
+		case *SpecialVar:
+			data = append(data, v.rewriteForInstance(n.Props()))
 		}
-
-		data = append(data, []byte("\nlet "+string(r.name)+";"))
 	}
 
 	for _, r := range nrmlRoots {
-		if n, ok := r.(rewriteAssignmenter); ok {
+		switch r.(type) {
+		case *ImportNode, *SpecialVar:
+			continue
+		}
+
+		if sv, ok := r.(*StoreVar); ok {
+			data = append(data, sv.rewriteForInstance(rw))
+		} else if pn, ok := r.(*PropNode); ok {
+			nData, _ := pn.rewriteForInstance(rw)
+			data = append(data, nData)
+		} else if en, ok := r.(*ExportNode); ok {
+			nData, _ := en.rewriteForInstance(rw)
+			data = append(data, nData)
+		} else if n, ok := r.(rewriteAssignmenter); ok {
 			nData, _ := n.rewriteAssignments(rw)
 			data = append(data, nData)
 		} else {
@@ -83,7 +114,7 @@ func (n *Script) RewriteForInstance(
 			for _, r := range ratvRoots {
 				if r.IsAssignment() {
 					updData, _ := r.rewriteAssignments(rw)
-					_, varNameInfo := NewVarNameRewriter(n, nil).Rewrite([]byte(r.body.Js()))
+					_, varNameInfo := NewVarNameRewriter(n, nil).Rewrite(r.valueJs())
 
 					updsData = append(
 						updsData,
@@ -107,22 +138,187 @@ func (n *Script) RewriteForInstance(
 }
 
 func (n *Script) Js() string {
-	return noRewriteJs(n)
+	data := []string{}
+	for _, r := range n.roots {
+		data = append(data, r.Js())
+	}
+	return strings.Join(data, "")
 }
 
-func (n *Script) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data := [][]byte{}
-	info := []*VarsInfo{}
-	for _, n := range n.roots {
-		if ra, ok := n.(rewriteAssignmenter); ok {
-			rootData, rootInfo := ra.rewriteAssignments(rw)
-			data = append(data, rootData)
-			info = append(info, rootInfo)
-		} else {
-			data = append(data, []byte(n.Js()))
+// Body returns the js of the script without the import declarations.
+func (n *Script) Body() string {
+	data := []string{}
+	for _, r := range n.roots {
+		if _, ok := r.(*ImportNode); !ok {
+			data = append(data, r.Js())
+		}
+	}
+	return strings.Join(data, "")
+}
+
+// Imports returns the import declarations, which have to be at the top level
+// of the module.
+func (n *Script) Imports() []string {
+	imports := []string{}
+	for _, r := range n.roots {
+		if in, ok := r.(*ImportNode); ok {
+			imports = append(imports, in.Js())
+		}
+	}
+	return imports
+}
+
+// ImportNames returns the names the imports of the script declare, which are
+// declared in the module.
+func (n *Script) ImportNames() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		if in, ok := r.(*ImportNode); ok {
+			names = append(names, in.ImportNames()...)
+		}
+	}
+	return names
+}
+
+// Names returns the names the script declares at its root, including the
+// names of its imports.
+func (n *Script) Names() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		switch v := r.(type) {
+		case *ImportNode:
+			names = append(names, v.ImportNames()...)
+		case Var:
+			names = append(names, v.VarNames()...)
+		}
+	}
+	return names
+}
+
+// Props returns the names of the variables that are exported as props.
+func (n *Script) Props() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		if pn, ok := r.(*PropNode); ok {
+			names = append(names, pn.VarNames()...)
+		}
+	}
+	return names
+}
+
+// Exports returns the names of the consts and functions that are exported,
+// which are read-only members of the component.
+func (n *Script) Exports() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		if en, ok := r.(*ExportNode); ok {
+			names = append(names, en.VarNames()...)
+		}
+	}
+	return names
+}
+
+// UseRefs finds the stores the js of the template references with a $
+// prefix, and the special $$ variables it uses. The stores are subscribed to
+// in the instance and the values get their own variables. The vars the js
+// references get a slot in ctx.
+func (n *Script) UseRefs(data []byte) {
+	prog, err := parseSnippet(data)
+	if err != nil {
+		return
+	}
+	n.useRefs(prog)
+
+	if n.used == nil {
+		n.used = map[string]bool{}
+	}
+	for _, r := range analyzeScopes(prog).rootRefs() {
+		n.used[r.ident.name] = true
+	}
+}
+
+func (n *Script) useRefs(prog *program) {
+	for _, r := range analyzeScopes(prog).rootRefs() {
+		name := r.ident.name
+		switch {
+		case isSpecialVar(name):
+			n.useSpecialVar(name)
+		case len(name) > 1 && name[0] == '$' && name[1] != '$':
+			n.useStore(name[1:])
+		}
+	}
+}
+
+func (n *Script) useSpecialVar(name string) {
+	for _, r := range n.roots {
+		if sv, ok := r.(*SpecialVar); ok && sv.name == name {
+			return
+		}
+	}
+
+	n.roots = append(n.roots, &SpecialVar{name})
+}
+
+// SpecialVars returns the names of the special $$ variables that are used.
+func (n *Script) SpecialVars() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		if sv, ok := r.(*SpecialVar); ok {
+			names = append(names, sv.name)
+		}
+	}
+	return names
+}
+
+// RebindableStores returns the names of the vars holding stores that can be
+// assigned another store.
+func (n *Script) RebindableStores() []string {
+	names := []string{}
+	for _, r := range n.roots {
+		if sv, ok := r.(*StoreVar); ok && sv.rebindable {
+			names = append(names, sv.store)
+		}
+	}
+	return names
+}
+
+func (n *Script) useStore(name string) {
+	for _, r := range n.roots {
+		if sv, ok := r.(*StoreVar); ok && sv.store == name {
+			return
+		}
+	}
+
+	for i, r := range n.roots {
+		switch v := r.(type) {
+		case *VarNode, *PropNode, *ExportNode, *ImportNode:
+			varNames := []string{}
+			if in, ok := v.(*ImportNode); ok {
+				varNames = in.ImportNames()
+			} else {
+				varNames = v.(Var).VarNames()
+			}
+
+			for _, varName := range varNames {
+				if varName != name {
+					continue
+				}
+
+				rebindable := false
+				switch v := v.(type) {
+				case *VarNode:
+					rebindable = v.VarType() != "const"
+				case *PropNode:
+					rebindable = v.VarType() != "const"
+				}
+
+				roots := append([]Node{}, n.roots[:i+1]...)
+				roots = append(roots, &StoreVar{name, rebindable})
+				n.roots = append(roots, n.roots[i+1:]...)
+				return
+			}
 		}
 	}
-	return bytes.Join(data, nil), MergeVarsInfo(info...)
 }
 
 func (n *Script) rootVars() []Var {
@@ -138,11 +334,62 @@ func (n *Script) rootVars() []Var {
 	return vars
 }
 
+// A ctxVar is a name of a root var that has a slot in the ctx of the
+// component.
+type ctxVar struct {
+	name string
+	v    Var
+}
+
+// ctxVars returns the root vars that get a slot in ctx, in the order of their
+// indexes. These are the props and exports, the stores and special vars, and
+// the vars the template or the reactive statements reference. All other vars
+// stay local to the instance, or are hoisted to the module.
+func (n *Script) ctxVars() []ctxVar {
+	used := map[string]bool{}
+	for name := range n.used {
+		used[name] = true
+	}
+	labels, _ := n.sortReactive()
+	for _, ln := range labels {
+		assignees, deps := ln.reactiveDeps()
+		for _, name := range append(assignees, deps...) {
+			used[name] = true
+		}
+	}
+
+	vars := []ctxVar{}
+	added := map[string]bool{}
+	for _, v := range n.rootVars() {
+		if n.hoisted[v] {
+			continue
+		}
+
+		for _, name := range v.VarNames() {
+			if added[name] {
+				continue
+			}
+			switch v.(type) {
+			case *PropNode, *ExportNode, *StoreVar, *SpecialVar:
+			default:
+				if !used[name] {
+					continue
+				}
+			}
+
+			added[name] = true
+			vars = append(vars, ctxVar{name, v})
+		}
+	}
+	return vars
+}
+
 func (n *Script) appendChild(child Node) {
 	n.roots = append(n.roots, child)
 }
 
-// A CommentNode represents a js comment (for reprinting).
+// A CommentNode represents the js comments and whitespace after the last
+// statement (for reprinting).
 type CommentNode struct {
 	content []byte
 }
@@ -151,78 +398,71 @@ func (n *CommentNode) Js() string {
 	return string(n.content)
 }
 
-// A childComments represents js comments inside another node (for reprinting).
-type childComments struct {
-	nodes []*CommentNode
+// A stmtNode is a root statement of a script, its content is the js of the
+// statement with the whitespace and comments before it.
+type stmtNode struct {
+	content []byte
+	offset  int
 }
 
-func (cs *childComments) appendNil() {
-	cs.nodes = append(cs.nodes, nil)
+func (n *stmtNode) Js() string {
+	return string(n.content)
 }
 
-func (cs *childComments) appendChild(node *CommentNode) {
-	cs.nodes = append(cs.nodes, node)
+// js gets the js of the part of the statement at the offsets of the script.
+func (n *stmtNode) js(s span) []byte {
+	return n.content[s.start-n.offset : s.end-n.offset]
 }
 
-func (cs *childComments) pop() {
-	cs.nodes = cs.nodes[:len(cs.nodes)-1]
+// leading gets the whitespace and comments before the statement.
+func (n *stmtNode) leading(stmt astNode) []byte {
+	return n.content[:stmt.pos().start-n.offset]
 }
 
-func (cs *childComments) injectBetween(allData ...[]byte) []byte {
-	js := []byte{}
-	notSet := 0
-	for i, data := range allData {
-		if len(data) == 0 {
-			notSet += 1
-			continue
-		}
-
-		js = append(js, data...)
-
-		for len(cs.nodes) <= i-notSet {
-			cs.appendNil()
-		}
-		if n := cs.nodes[i-notSet]; n != nil {
-			js = append(js, []byte(n.Js())...)
-		}
-	}
-	return js
+func (n *stmtNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
+	return rewriteJs(rw, n.content)
 }
 
 // A LabelNode reprents a labeled js statement.
 type LabelNode struct {
-	label    []byte
-	name     []byte
-	equals   []byte
-	body     Node
-	simi     []byte
-	comments *childComments
+	stmtNode
+	stmt *labeledStmt
 }
 
 func (n *LabelNode) VarType() string {
-	return trimLeftSpaces(n.label)
+	return n.Label()
 }
 
 func (n *LabelNode) VarNames() []string {
-	if len(n.name) == 0 {
+	assign := n.assignment()
+	if assign == nil {
 		return nil
 	}
 
-	//TODO, add support for destructuring
-	return []string{trimLeftSpaces(n.name)}
+	names := []string{}
+	for _, ident := range bindingNames(assign.target) {
+		names = append(names, ident.name)
+	}
+	return names
 }
 
-func (n *LabelNode) Label() string {
-	return strings.TrimFunc(string(n.label), func(r rune) bool {
-		switch {
-		case unicode.IsSpace(r):
-			return true
-		case r == rune(labelSufix[0]):
-			return true
-		}
+// assignment gets the assignment that is the body of the statement, if it
+// is one.
+func (n *LabelNode) assignment() *assignExpr {
+	es, ok := n.stmt.body.(*exprStmt)
+	if !ok {
+		return nil
+	}
 
-		return false
-	})
+	assign, ok := unparen(es.expr).(*assignExpr)
+	if !ok || assign.op != "=" {
+		return nil
+	}
+	return assign
+}
+
+func (n *LabelNode) Label() string {
+	return n.stmt.label.name
 }
 
 const reactiveLabel = "$"
@@ -232,357 +472,278 @@ func (n *LabelNode) IsReactive() bool {
 }
 
 func (n *LabelNode) IsAssignment() bool {
-	return len(n.name) > 0
+	return len(n.VarNames()) > 0
 }
 
-func (n *LabelNode) Js() string {
-	return noRewriteJs(n)
-}
-
-func (n *LabelNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data := [][]byte{}
-	info := []*VarsInfo{}
-
-	data = append(data, n.label)
-
-	if len(n.name) != 0 {
-		data = append(data, n.name)
-		data = append(data, n.equals)
-	}
-
-	if ra, ok := n.body.(rewriteAssignmenter); ok {
-		raData, raInfo := ra.rewriteAssignments(rw)
-		data = append(data, raData)
-		info = append(info, raInfo)
-	} else {
-		data = append(data, []byte(n.body.Js()))
-	}
-
-	if len(n.name) == 0 {
-		return n.comments.injectBetween(data...), MergeVarsInfo(info...)
-	}
-
-	data = append(data, n.simi)
-	allData := n.comments.injectBetween(data...)
-	if rw == nil {
-		return allData, NewEmptyVarsInfo()
-	}
-	return rw.Rewrite(allData)
+// valueJs gets the js of the value that is assigned by the statement.
+func (n *LabelNode) valueJs() []byte {
+	return n.js(n.assignment().value.pos())
 }
 
 // A VarNode represents a js variable initlization/declarion.
 type VarNode struct {
-	keyword  []byte
-	name     []byte
-	equals   []byte
-	value    *BlockNode
-	simi     []byte
-	comments *childComments
+	stmtNode
+	decl *varDecl
 }
 
 func (n *VarNode) VarType() string {
-	return trimLeftSpaces(n.keyword)
+	return n.decl.kind
 }
 
 func (n *VarNode) VarNames() []string {
-	if len(n.name) == 0 {
-		return nil
-	}
-
-	//TODO, add support for destructuring
-	return []string{trimLeftSpaces(n.name)}
+	return declNames(n.decl)
 }
 
-func (n *VarNode) Js() string {
-	return noRewriteJs(n)
+func declNames(decl *varDecl) []string {
+	names := []string{}
+	for _, d := range decl.decls {
+		for _, ident := range bindingNames(d.target) {
+			names = append(names, ident.name)
+		}
+	}
+	return names
 }
 
-func (n *VarNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data := [][]byte{}
+// A PropNode represents an exported js variable, which is a component prop.
+type PropNode struct {
+	stmtNode
+	export *exportDecl
+	decl   *varDecl
+}
 
-	data = append(data, n.keyword)
-	data = append(data, n.name)
+func (n *PropNode) VarType() string {
+	return n.decl.kind
+}
 
-	if len(n.equals) != 0 {
-		data = append(data, n.equals)
+func (n *PropNode) VarNames() []string {
+	return declNames(n.decl)
+}
 
-		valueData, info := n.value.rewriteAssignments(rw)
-		data = append(data, valueData)
-		data = append(data, n.simi)
-		return n.comments.injectBetween(data...), info
+// rewriteForInstance will initialize the variables from the $$props passed to
+// the instance, keeping the values as the defaults.
+func (n *PropNode) rewriteForInstance(rw VarRewriter) ([]byte, *VarsInfo) {
+	decls := [][]byte{}
+	for _, d := range n.decl.decls {
+		decls = append(decls, n.js(d.span))
 	}
 
-	data = append(data, n.simi)
-	return n.comments.injectBetween(data...), NewEmptyVarsInfo()
+	data := append([]byte{}, n.leading(n.export)...)
+	data = append(data, []byte(n.decl.kind+" { ")...)
+	data = append(data, bytes.Join(decls, []byte(", "))...)
+	data = append(data, []byte(" } = $$props;")...)
+	return rewriteJs(rw, data)
 }
 
-// A FuncNode represents a js function.
-type FuncNode struct {
-	keyword  []byte
-	name     []byte
-	params   []byte
-	body     *BlockNode
-	comments *childComments
+// An ExportNode represents an exported js const, function or class, which is
+// a read-only member of the component.
+type ExportNode struct {
+	stmtNode
+	export *exportDecl
 }
 
-func (n *FuncNode) VarType() string {
-	return funcKeyword
+func (n *ExportNode) VarType() string {
+	switch decl := n.export.decl.(type) {
+	case *varDecl:
+		return decl.kind
+	case *classExpr:
+		return "class"
+	}
+	return "function"
 }
 
-func (n *FuncNode) VarNames() []string {
-	if len(n.name) == 0 {
-		return nil
+func (n *ExportNode) VarNames() []string {
+	switch decl := n.export.decl.(type) {
+	case *varDecl:
+		return declNames(decl)
+	case *funcExpr:
+		return []string{decl.name.name}
+	case *classExpr:
+		return []string{decl.name.name}
 	}
-
-	return []string{trimLeftSpaces(n.name)}
+	return nil
 }
 
-func (n *FuncNode) Js() string {
-	return noRewriteJs(n)
+// rewriteForInstance will declare the const or function without the export
+// keyword, which isn't valid inside of the instance.
+func (n *ExportNode) rewriteForInstance(rw VarRewriter) ([]byte, *VarsInfo) {
+	data := append([]byte{}, n.leading(n.export)...)
+	data = append(data, n.js(span{n.export.decl.pos().start, n.export.end})...)
+	return rewriteJs(rw, data)
 }
 
-func (n *FuncNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data, info := n.body.rewriteAssignments(rw)
-	return n.comments.injectBetween(
-		n.keyword,
-		n.name,
-		n.params,
-		data,
-	), info
+// A StoreVar represents the $ prefixed variable that holds the value of a
+// store, it follows the declaration of the store in the script.
+type StoreVar struct {
+	store      string
+	rebindable bool // the store is held by a let or a prop, so it can change
 }
 
-// A ClassNode represents a js class.
-type ClassNode struct {
-	classKeyword   []byte
-	name           []byte
-	extendsKeyword []byte
-	superName      []byte
-	body           *BlockNode
-	comments       *childComments
+func (n *StoreVar) VarType() string {
+	return "let"
 }
 
-func (n *ClassNode) VarNames() []string {
-	if len(n.name) == 0 {
-		return nil
-	}
-
-	return []string{trimLeftSpaces(n.name)}
+func (n *StoreVar) VarNames() []string {
+	return []string{"$" + n.store}
 }
 
-func (n *ClassNode) Js() string {
-	return noRewriteJs(n)
+// Store returns the name of the variable holding the store.
+func (n *StoreVar) Store() string {
+	return n.store
 }
 
-func (n *ClassNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data, info := n.body.rewriteAssignments(rw)
-	return n.comments.injectBetween(
-		n.classKeyword,
-		n.name,
-		n.extendsKeyword,
-		n.superName,
-		data,
-	), info
-}
-
-// An IfNode represents a js if/else[if] statement.
-type IfNode struct {
-	ifKeyword   []byte
-	params      []byte
-	ifBody      *BlockNode
-	elseKeyword []byte
-	elseBody    *BlockNode
-	elseIfNode  *IfNode
-	comments    *childComments
-}
-
-func (n *IfNode) Js() string {
-	if len(n.elseKeyword) == 0 {
-		return string(n.comments.injectBetween(
-			n.ifKeyword,
-			n.params,
-			[]byte(n.ifBody.Js()),
-		))
-	}
-
-	if n.elseBody != nil {
-		return string(n.comments.injectBetween(
-			n.ifKeyword,
-			n.params,
-			[]byte(n.ifBody.Js()),
-			n.elseKeyword,
-			[]byte(n.elseBody.Js()),
-		))
-	}
-
-	js := string(n.comments.injectBetween(
-		n.ifKeyword,
-		n.params,
-		[]byte(n.ifBody.Js()),
-		n.elseKeyword,
-	))
-	js += n.elseIfNode.Js()
-	return js
-}
-
-func (n *IfNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data := [][]byte{}
-	info := []*VarsInfo{}
-
-	data = append(data, n.ifKeyword)
-	data = append(data, n.params)
-
-	ifData, ifInfo := n.ifBody.rewriteAssignments(rw)
-	data = append(data, ifData)
-	info = append(info, ifInfo)
-
-	if n.elseBody != nil {
-		data = append(data, n.elseKeyword)
+func (n *StoreVar) Js() string {
+	return ""
+}
 
-		elseData, elseInfo := n.elseBody.rewriteAssignments(rw)
-		data = append(data, elseData)
-		info = append(info, elseInfo)
+// declareForInstance will declare the variable, along with the function that
+// subscribes it to the store again when the store can change.
+func (n *StoreVar) declareForInstance(rw VarRewriter) []byte {
+	if !n.rebindable {
+		return []byte("\nlet $" + n.store + ";")
 	}
 
-	if n.elseIfNode != nil {
-		data = append(data, n.elseKeyword)
+	unsubscribe := "$$unsubscribe_" + n.store
+	subscribe := "$$subscribe_" + n.store + " = () => (" + unsubscribe + "(), " +
+		unsubscribe + " = subscribe(" + n.store + ", value => " + string(n.setValue(rw)) + "), " + n.store + ")"
+	return []byte("\nlet $" + n.store + ", " + unsubscribe + " = noop, " + subscribe + ";" +
+		"\n$$self.$$.on_destroy.push(() => " + unsubscribe + "());")
+}
 
-		elseIfData, elseIfInfo := n.elseIfNode.rewriteAssignments(rw)
-		data = append(data, elseIfData)
-		info = append(info, elseIfInfo)
+// rewriteForInstance will subscribe to the store, setting the variable to
+// each value of it.
+func (n *StoreVar) rewriteForInstance(rw VarRewriter) []byte {
+	if n.rebindable {
+		return []byte("\n$$subscribe_" + n.store + "();")
 	}
-
-	return n.comments.injectBetween(data...), MergeVarsInfo(info...)
+	return []byte("\ncomponent_subscribe($$self, " + n.store + ", value => " + string(n.setValue(rw)) + ");")
 }
 
-// A basicCtrlStructNode represents basic js controll structures.
-type basicCtrlStructNode struct {
-	keyword  []byte
-	params   []byte
-	body     *BlockNode
-	comments *childComments
+// setValue gets the js that sets the variable to the value of the store.
+func (n *StoreVar) setValue(rw VarRewriter) []byte {
+	setData := []byte("$" + n.store + " = value")
+	if arw, ok := rw.(*astVarRewriter); ok {
+		invalidateRw := *arw
+		invalidateRw.setsStores = false
+		setData, _ = invalidateRw.Rewrite(setData)
+	}
+	return setData
 }
 
-func (n *basicCtrlStructNode) Js() string {
-	return noRewriteJs(n)
-}
+const (
+	propsVar     = "$$props"
+	restPropsVar = "$$restProps"
+	slotsVar     = "$$slots"
+)
 
-func (n *basicCtrlStructNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data, info := n.body.rewriteAssignments(rw)
-	return n.comments.injectBetween(
-		n.keyword,
-		n.params,
-		data,
-	), info
+func isSpecialVar(name string) bool {
+	return name == propsVar || name == restPropsVar || name == slotsVar
 }
 
-// A SwitchNode represents a js switch statement.
-type SwitchNode struct {
-	basicCtrlStructNode
+// A SpecialVar represents one of the $$ variables that give access to all the
+// props and slots passed to the component.
+type SpecialVar struct {
+	name string
 }
 
-// A WithNode represents a js with statement.
-type WithNode struct {
-	basicCtrlStructNode
+func (n *SpecialVar) VarType() string {
+	return "let"
 }
 
-// A ForNode represents a js for loop.
-type ForLoopNode struct {
-	basicCtrlStructNode
+func (n *SpecialVar) VarNames() []string {
+	return []string{n.name}
 }
 
-// A WhileNode represents a js while loop.
-type WhileLoopNode struct {
-	basicCtrlStructNode
+func (n *SpecialVar) Js() string {
+	return ""
 }
 
-// A DoWhileNode represents a js do while loop.
-type DoWhileLoopNode struct {
-	doKeyword    []byte
-	body         *BlockNode
-	whileKeyword []byte
-	params       []byte
-	simi         []byte
-	comments     *childComments
+// rewriteForInstance will declare the variable, from the $$props passed to the
+// instance.
+func (n *SpecialVar) rewriteForInstance(props []string) []byte {
+	switch n.name {
+	case restPropsVar:
+		quoted := []string{}
+		for _, prop := range props {
+			quoted = append(quoted, "'"+prop+"'")
+		}
+		return []byte(
+			"\nconst omit_props_names = [" + strings.Join(quoted, ", ") + "];" +
+				"\nlet $$restProps = compute_rest_props($$props, omit_props_names);",
+		)
+	case slotsVar:
+		return []byte("\nconst $$slots = compute_slots($$props.$$slots || {});")
+	}
+	return []byte{}
 }
 
-func (n *DoWhileLoopNode) Js() string {
-	return noRewriteJs(n)
+// A FuncNode represents a js function declaration.
+type FuncNode struct {
+	stmtNode
+	fn *funcExpr
 }
 
-func (n *DoWhileLoopNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data, info := n.body.rewriteAssignments(rw)
-	return n.comments.injectBetween(
-		n.doKeyword,
-		data,
-		n.whileKeyword,
-		n.params,
-		n.simi,
-	), info
+func (n *FuncNode) VarType() string {
+	return "function"
 }
 
-// A TryCatchNode represents a js try catch statement.
-type TryCatchNode struct {
-	tryKeyword     []byte
-	tryBody        *BlockNode
-	catchKeyword   []byte
-	params         []byte
-	catchBody      *BlockNode
-	finallyKeyword []byte
-	finallyBody    *BlockNode
-	comments       *childComments
+func (n *FuncNode) VarNames() []string {
+	return []string{n.fn.name.name}
 }
 
-func (n *TryCatchNode) Js() string {
-	return noRewriteJs(n)
+// A ClassNode represents a js class declaration.
+type ClassNode struct {
+	stmtNode
+	class *classExpr
 }
 
-func (n *TryCatchNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	data := [][]byte{}
-
-	data = append(data, n.tryKeyword)
-
-	tryData, tryInfo := n.tryBody.rewriteAssignments(rw)
-	data = append(data, tryData)
-	data = append(data, n.catchKeyword)
-	data = append(data, n.params)
-
-	catchData, catchInfo := n.catchBody.rewriteAssignments(rw)
-	data = append(data, catchData)
-
-	if n.finallyBody == nil {
-		return n.comments.injectBetween(data...), MergeVarsInfo(tryInfo, catchInfo)
-	}
-
-	data = append(data, n.finallyKeyword)
-
-	finallyData, finallyInfo := n.finallyBody.rewriteAssignments(rw)
-	data = append(data, finallyData)
+func (n *ClassNode) VarType() string {
+	return "class"
+}
 
-	return n.comments.injectBetween(data...), MergeVarsInfo(tryInfo, catchInfo, finallyInfo)
+func (n *ClassNode) VarNames() []string {
+	return []string{n.class.name.name}
 }
 
-// A BlockNode represents a block of js code that is not one of the other node types.
-type BlockNode struct {
+// An ImportNode represents a js import declaration, or an export declaration
+// that re-exports from another module.
+type ImportNode struct {
 	content []byte
 }
 
-func (n *BlockNode) Js() string {
-	return noRewriteJs(n)
+func (n *ImportNode) Js() string {
+	return string(n.content)
 }
 
-func (n *BlockNode) rewriteAssignments(rw VarRewriter) ([]byte, *VarsInfo) {
-	if rw == nil {
-		return n.content, NewEmptyVarsInfo()
+// ImportNames returns the names of the bindings the import declares.
+func (n *ImportNode) ImportNames() []string {
+	names := []string{}
+	prog, err := parseProgram(n.content)
+	if err != nil {
+		return names
 	}
 
-	return rw.Rewrite(n.content)
+	for _, stmt := range prog.body {
+		if decl, ok := stmt.(*importDecl); ok {
+			for _, spec := range decl.specs {
+				names = append(names, spec.local.name)
+			}
+		}
+	}
+	return names
 }
 
-func noRewriteJs(rw rewriteAssignmenter) string {
-	data, _ := rw.rewriteAssignments(nil)
-	return string(data)
+// A BlockNode represents a js statement that is not one of the other node
+// types.
+type BlockNode struct {
+	stmtNode
 }
 
-func trimLeftSpaces(data []byte) string {
-	return strings.TrimLeftFunc(string(data), unicode.IsSpace)
+// rewriteJs rewrites all of the js at once, so the declarations of params and
+// bodies are in the scopes of the code that references them.
+func rewriteJs(rw VarRewriter, data []byte) ([]byte, *VarsInfo) {
+	if rw == nil {
+		return data, NewEmptyVarsInfo()
+	}
+
+	return rw.Rewrite(data)
 }
@@ -11,355 +11,94 @@ func Parse(src io.Reader) (*Script, error) {
 	if err != nil {
 		return nil, err
 	}
-	lex := startNewLexer(lexScript, data)
 
 	script := &Script{}
-	if err := script.parse(lex); err != nil {
+	prog, err := parseProgram(data)
+	if err != nil {
 		return script, err
 	}
+	script.prog = prog
 
-	return script, nil
-}
-
-type parser interface {
-	parse(*lexer) error
-}
-
-func (n *Script) parse(lex *lexer) error {
-	for tt, data := lex.Next(); tt != eofType; {
-		var nextNode interface {
-			Node
-			parser
-		}
-		switch tt {
-		case errorType:
-			return errors.New(string(data))
-		case commentType:
-			nextNode = &CommentNode{}
-		case labelType:
-			nextNode = &LabelNode{}
-		case keywordType:
-			nextNode, _ = nodeForKeyword(trimLeftSpaces(data))
-		}
-		if nextNode == nil {
-			nextNode = &BlockNode{}
-		}
-
-		lex.rewind(tt, data)
-		if err := nextNode.parse(lex); err != nil {
-			return err
-		}
-		n.appendChild(nextNode)
-
-		tt, data = lex.Next()
-	}
-
-	return nil
-}
-
-func (n *CommentNode) parse(lex *lexer) error {
-	_, data := lex.Next()
-	n.content = data
-	return nil
-}
-
-func (n *LabelNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	_, data := ncLex.Next()
-	n.label = data
-
-	tt, data := ncLex.Next()
-	switch tt {
-	case varNameType:
-		n.name = data
-
-		tt, data = ncLex.Next()
-		if tt != eqOpType {
-			return errors.New("Equal sign must follow variable name in labeled assignment")
-		}
-		n.equals = data
-
-		tt, data = ncLex.Next()
-		if tt != codeBlockType {
-			return errors.New("Expression must follow equals sign in labled assigment")
-		}
-		nextNode := &BlockNode{}
-		ncLex.rewind(tt, data)
-		if err := nextNode.parse(lex); err != nil {
-			return err
+	offset := 0
+	for _, stmt := range prog.body {
+		end := stmt.pos().end
+		node, err := nodeForStmt(stmtNode{data[offset:end], offset}, stmt)
+		if err != nil {
+			return script, err
 		}
-		n.body = nextNode
-
-		tt, data = ncLex.Next()
-		if tt == simiOpType {
-			n.simi = data
-		} else {
-			ncLex.rewind(tt, data)
-		}
-	case keywordType:
-		nextNode, ok := nodeForKeyword(trimLeftSpaces(data))
-		if !ok {
-			return errors.New("Invalid keyword following label")
-		}
-		ncLex.rewind(tt, data)
-		if err := nextNode.parse(lex); err != nil {
-			return err
-		}
-		n.body = nextNode
-	case codeBlockType:
-		nextNode := &BlockNode{}
-		ncLex.rewind(tt, data)
-		if err := nextNode.parse(lex); err != nil {
-			return err
-		}
-		n.body = nextNode
-	}
-	return nil
-}
-
-func (n *VarNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.keyword = data
-
-	tt, data = ncLex.Next()
-	if tt != varNameType {
-		return errors.New("Variable not given a name")
-	}
-	n.name = data
-
-	tt, data = ncLex.Next()
-	if tt == simiOpType {
-		n.simi = data
-		return nil
-	}
-	if tt != eqOpType {
-		ncLex.rewind(tt, data)
-		return nil
-	}
-	n.equals = data
-
-	tt, data = ncLex.Next()
-	if tt != codeBlockType {
-		return errors.New("Variable with '=' sign not given a value")
-	}
-	n.value = &BlockNode{}
-	lex.rewind(tt, data)
-	if err := n.value.parse(lex); err != nil {
-		return err
-	}
-
-	tt, data = ncLex.Next()
-	if tt == simiOpType {
-		n.simi = data
-	} else {
-		ncLex.rewind(tt, data)
-	}
-	return nil
-}
-
-func (n *FuncNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.keyword = data
-
-	tt, data = ncLex.Next()
-	if tt == varNameType {
-		n.name = data
-
-		tt, data = ncLex.Next()
+		script.appendChild(node)
+		offset = end
 	}
-	if tt != paramsType {
-		return errors.New("Function not given arguments")
+	if offset < len(data) {
+		script.appendChild(&CommentNode{data[offset:]})
 	}
-	n.params = data
+	script.useRefs(prog)
 
-	tt, data = ncLex.Next()
-	if tt != codeBlockType {
-		return errors.New("Function not given body")
+	if _, err := script.sortReactive(); err != nil {
+		return script, err
 	}
-	n.body = &BlockNode{}
-	lex.rewind(tt, data)
-	return n.body.parse(lex)
+	return script, nil
 }
 
-func (n *ClassNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.classKeyword = data
-
-	tt, data = ncLex.Next()
-	if tt == varNameType {
-		n.name = data
-
-		tt, data = ncLex.Next()
+// FreeNames parses the js of a module and returns the names it references
+// without declaring them, which are the globals and the names it expects to
+// be imported, in the order they're first referenced.
+func FreeNames(data []byte) ([]string, error) {
+	prog, err := parseProgram(data)
+	if err != nil {
+		return nil, err
 	}
-	if tt == keywordType {
-		if trimLeftSpaces(data) != extendsKeyword {
-			return errors.New("Invalid keyword in class declaration")
-		}
-		n.extendsKeyword = data
 
-		tt, data = ncLex.Next()
-		if tt == varNameType {
-			return errors.New("No super class name after extends keyword")
+	names := []string{}
+	seen := map[string]bool{}
+	for _, r := range analyzeScopes(prog).refs {
+		name := r.ident.name
+		if r.scope.lookup(name) == nil && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
 		}
-		n.superName = data
-
-		tt, data = ncLex.Next()
-	}
-
-	if tt != codeBlockType {
-		return errors.New("Class not given a body")
 	}
-	n.body = &BlockNode{}
-	lex.rewind(tt, data)
-	return n.body.parse(lex)
+	return names, nil
 }
 
-func (n *IfNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.ifKeyword = data
-
-	tt, data = ncLex.Next()
-	if tt != paramsType {
-		return errors.New("If statement not given parameters")
-	}
-	n.params = data
-
-	tt, data = ncLex.Next()
-	if tt != codeBlockType {
-		return errors.New("If statement not given body")
-	}
-	ncLex.rewind(tt, data)
-	n.ifBody = &BlockNode{}
-	if err := n.ifBody.parse(lex); err != nil {
-		return err
-	}
-
-	tt, data = ncLex.Next()
-	if tt != keywordType || trimLeftSpaces(data) != elseKeyword {
-		ncLex.rewind(tt, data)
-		return nil
-	}
-	n.elseKeyword = data
-
-	tt, data = ncLex.Next()
-	if tt == codeBlockType {
-		ncLex.rewind(tt, data)
-		n.elseBody = &BlockNode{}
-		return n.elseBody.parse(lex)
-	}
-	if tt != keywordType || trimLeftSpaces(data) != ifKeyword {
-		return errors.New("Invalid input following else keyword")
-	}
-
-	ncLex.rewind(tt, data)
-	n.elseIfNode = &IfNode{}
-	return n.elseIfNode.parse(lex)
+// nodeForStmt creates the node for a root statement of the script.
+func nodeForStmt(sn stmtNode, stmt astNode) (Node, error) {
+	switch stmt := stmt.(type) {
+	case *labeledStmt:
+		return &LabelNode{sn, stmt}, nil
+	case *varDecl:
+		return &VarNode{sn, stmt}, nil
+	case *funcExpr:
+		return &FuncNode{sn, stmt}, nil
+	case *classExpr:
+		return &ClassNode{sn, stmt}, nil
+	case *importDecl:
+		return &ImportNode{sn.content}, nil
+	case *exportDecl:
+		return nodeForExport(sn, stmt)
+	}
+	return &BlockNode{sn}, nil
 }
 
-func (n *basicCtrlStructNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.keyword = data
-
-	tt, data = ncLex.Next()
-	if tt != paramsType {
-		return errors.New("Control structure not given parameters")
-	}
-	n.params = data
-
-	tt, data = ncLex.Next()
-	if tt != codeBlockType {
-		return errors.New("Control structure not given body")
-	}
-	n.body = &BlockNode{}
-	lex.rewind(tt, data)
-	return n.body.parse(lex)
-}
-
-func (n *DoWhileLoopNode) parse(lex *lexer) error {
-	n.comments = &childComments{}
-	ncLex := &noCommentLexer{lex, n.comments}
-
-	tt, data := ncLex.Next()
-	n.doKeyword = data
-
-	tt, data = ncLex.Next()
-	if tt != codeBlockType {
-		return errors.New("Do while loop not given body")
-	}
-	n.body = &BlockNode{}
-	lex.rewind(tt, data)
-	if err := n.body.parse(lex); err != nil {
-		return err
-	}
-
-	tt, data = ncLex.Next()
-	if tt != keywordType || trimLeftSpaces(data) != whileKeyword {
-		return errors.New("Do while loop expected while keyword after body")
-	}
-	n.whileKeyword = data
-
-	tt, data = ncLex.Next()
-	if tt != paramsType {
-		return errors.New("Do while loop not given parameters")
-	}
-	n.params = data
-
-	tt, data = ncLex.Next()
-	if tt == simiOpType {
-		n.simi = data
-	} else {
-		ncLex.rewind(tt, data)
-	}
-	return nil
-}
-
-func (n *BlockNode) parse(lex *lexer) error {
-	_, data := lex.Next()
-	n.content = data
-	return nil
-}
-
-func nodeForKeyword(kw string) (interface {
-	Node
-	parser
-}, bool) {
-	switch kw {
-	case varKeyword, letKeyword, constKeyword:
-		return &VarNode{}, true
-	case funcKeyword:
-		return &FuncNode{}, true
-	case classKeyword:
-		return &ClassNode{}, true
-	case ifKeyword:
-		return &IfNode{}, true
-	case switchKeyword:
-		return &SwitchNode{}, true
-	case withKeyword:
-		return &WithNode{}, true
-	case forKeyword:
-		return &ForLoopNode{}, true
-	case whileKeyword:
-		return &WhileLoopNode{}, true
-	case doKeyword:
-		return &DoWhileLoopNode{}, true
+// nodeForExport creates the node for an export declaration, exported lets and
+// vars are props and everything else that is declared is a read-only member.
+func nodeForExport(sn stmtNode, export *exportDecl) (Node, error) {
+	switch {
+	case export.source != "":
+		return &ImportNode{sn.content}, nil
+	case export.dflt:
+		return nil, errors.New("Unsupported export in component script, export default")
+	case export.decl == nil:
+		return nil, errors.New("Unsupported export in component script, export { ... }")
+	}
+
+	if decl, ok := export.decl.(*varDecl); ok && decl.kind != "const" {
+		for _, d := range decl.decls {
+			if _, ok := d.target.(*identExpr); !ok {
+				return nil, errors.New("Unsupported export in component script, destructured prop")
+			}
+		}
+		return &PropNode{sn, export, decl}, nil
 	}
-
-	return nil, false
+	return &ExportNode{sn, export}, nil
 }
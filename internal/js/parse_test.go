@@ -2,6 +2,7 @@ package js
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +19,18 @@ func TestParseAndReprint(t *testing.T) {
 			*/`,
 		)},
 		{"VariableDeclaration", []byte("let some = 'value';")},
+		{"PropDeclaration", []byte("export let some = 'value';")},
+		{"ExportDeclarations", []byte(
+			`export const VERSION = '1.0';
+			export function reset() {
+				count = 0;
+			}`,
+		)},
+		{"ImportDeclarations", []byte(
+			`import Some from './Some.elem'
+			import { other, some as another } from "./other";
+			export * from './more';`,
+		)},
 		{"FunctionDeclaration", []byte(
 			`function func(args) {
 				return 'value';
@@ -109,11 +122,27 @@ func TestParseAndReprint(t *testing.T) {
 				finalFunc()
 			}`,
 		)},
+		{"AsyncAndGenerators", []byte(
+			`async function load() {
+				await fetch('/api')
+			}
+			const double = (x) => x * 2
+			function* ids() {
+				yield 1
+			}`,
+		)},
+		{"OtherLabel", []byte(
+			`outer: for (const row of rows) {
+				for (const cell of row) {
+					if (!cell) continue outer
+				}
+			}`,
+		)},
 		{"RootExpr", []byte(
 			`some.func({
 				param: 'name',
 				method() {
-					reutnr 'value';
+					return 'value';
 				}
 			});`,
 		)},
@@ -192,3 +221,228 @@ $: {
 		})
 	}
 }*/
+
+func TestVarNames(t *testing.T) {
+	testData := []struct {
+		name   string
+		input  string
+		output []string
+	}{
+		{"AsyncFunction", "async function load() {\n\tawait fetch('/api');\n}", []string{"load"}},
+		{"ConstArrow", "const double = (x) => x * 2;", []string{"double"}},
+		{"Generator", "function* ids() {\n\tyield 1;\n}", []string{"ids"}},
+		{"ASI", "let a = 1\nlet b = a\n++b\nlet c", []string{"a", "b", "c"}},
+		{"RegexpAfterLabel", "$: matches = /a\\/b/.test(value)\nlet value", []string{"matches", "value"}},
+		{"NotReactiveLabel", "other: {\n\tbreak other\n}", []string{}},
+		{"MultipleDeclarators", "let a = 1, b = 2;", []string{"a", "b"}},
+		{"Destructuring", "const { a, b: [c, d = 1], ...e } = obj;", []string{"a", "c", "d", "e"}},
+		{"ReactiveDestructuring", "$: ({ a, b } = obj);\n$: [c, ...d] = arr;", []string{"a", "b", "c", "d"}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			script, err := Parse(strings.NewReader(td.input))
+			if err != nil {
+				t.Fatalf("Parse return error: %q", err.Error())
+			}
+
+			names := []string{}
+			for _, v := range script.rootVars() {
+				names = append(names, v.VarNames()...)
+			}
+			if strings.Join(names, ",") != strings.Join(td.output, ",") {
+				t.Fatalf("Expected var names %v but got %v", td.output, names)
+			}
+		})
+	}
+}
+
+func TestImportNames(t *testing.T) {
+	testData := []struct {
+		name   string
+		input  string
+		output []string
+	}{
+		{"Default", "import Some from './Some.elem';", []string{"Some"}},
+		{"Named", "import { some, other as another } from './other';", []string{"some", "another"}},
+		{"Namespace", "import * as all from './all';", []string{"all"}},
+		{"DefaultAndNamed", "import Some, { other } from './other';", []string{"Some", "other"}},
+		{"SideEffect", "import './styles';", []string{}},
+		{"ExportFrom", "export { some } from './other';", []string{}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			names := (&ImportNode{[]byte(td.input)}).ImportNames()
+			if strings.Join(names, ",") != strings.Join(td.output, ",") {
+				t.Fatalf("Expected names %v but got %v", td.output, names)
+			}
+		})
+	}
+}
+
+func TestSpecialVars(t *testing.T) {
+	testData := []struct {
+		name   string
+		input  string
+		output []string
+	}{
+		{"None", "let a = props;", []string{}},
+		{"Props", "let a = $$props.a;", []string{"$$props"}},
+		{"RestPropsAndSlots", "let a = $$restProps;\nlet b = $$slots.b && $$restProps;", []string{"$$restProps", "$$slots"}},
+		{"NotStore", "let a = $$props;\nlet b = $a;", []string{"$$props"}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			script, err := Parse(strings.NewReader(td.input))
+			if err != nil {
+				t.Fatalf("Parse return error: %q", err.Error())
+			}
+
+			names := script.SpecialVars()
+			if strings.Join(names, ",") != strings.Join(td.output, ",") {
+				t.Fatalf("Expected special vars %v but got %v", td.output, names)
+			}
+		})
+	}
+}
+
+func TestExports(t *testing.T) {
+	testData := []struct {
+		name    string
+		input   string
+		exports []string
+		props   []string
+	}{
+		{"Const", "export const VERSION = '1.0';", []string{"VERSION"}, []string{}},
+		{"Function", "export function reset() {\n\tcount = 0;\n}", []string{"reset"}, []string{}},
+		{"WithProps", "export let name;\nexport const VERSION = '1.0';", []string{"VERSION"}, []string{"name"}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			script, err := Parse(strings.NewReader(td.input))
+			if err != nil {
+				t.Fatalf("Parse return error: %q", err.Error())
+			}
+
+			if exports := script.Exports(); strings.Join(exports, ",") != strings.Join(td.exports, ",") {
+				t.Fatalf("Expected exports %v but got %v", td.exports, exports)
+			}
+			if props := script.Props(); strings.Join(props, ",") != strings.Join(td.props, ",") {
+				t.Fatalf("Expected props %v but got %v", td.props, props)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testData := []struct {
+		name  string
+		input string
+	}{
+		{"InvalidSyntax", "let a = ;"},
+		{"UnclosedBlock", "function f() {"},
+		{"DefaultExport", "export default {};"},
+		{"ExportSpecifiers", "let a;\nexport { a };"},
+		{"DestructuredProp", "export let { a } = obj;"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(td.input)); err == nil {
+				t.Fatalf("Expected %q to return an error", td.input)
+			}
+		})
+	}
+}
+
+func TestReactiveOrder(t *testing.T) {
+	testData := []struct {
+		name   string
+		input  string
+		output []string
+	}{
+		{"SourceOrder", "$: b = a * 2;\n$: c = a * 3;", []string{"$: b = a * 2;", "$: c = a * 3;"}},
+		{"AssignedLater", "$: c = b * 2;\n$: b = a + 1;\nlet a = 1;", []string{"$: b = a + 1;", "$: c = b * 2;"}},
+		{"Statement", "$: console.log(total);\n$: total = items.length;", []string{"$: total = items.length;", "$: console.log(total);"}},
+		{"Chain", "$: d = c;\n$: c = b;\n$: b = a;", []string{"$: b = a;", "$: c = b;", "$: d = c;"}},
+		{"SelfAssignment", "$: count = count + 1;\n$: double = count * 2;", []string{"$: count = count + 1;", "$: double = count * 2;"}},
+		{"Destructuring", "$: sum = x + y;\n$: ({ x, y } = point);", []string{"$: ({ x, y } = point);", "$: sum = x + y;"}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			script, err := Parse(strings.NewReader(td.input))
+			if err != nil {
+				t.Fatalf("Parse return error: %q", err.Error())
+			}
+
+			labels, _ := script.sortReactive()
+			order := []string{}
+			for _, ln := range labels {
+				order = append(order, strings.TrimSpace(ln.Js()))
+			}
+			if strings.Join(order, "\n") != strings.Join(td.output, "\n") {
+				t.Fatalf("Expected order %q but got %q", td.output, order)
+			}
+		})
+	}
+}
+
+func TestReactiveCycles(t *testing.T) {
+	testData := []struct {
+		name  string
+		input string
+		cycle string
+	}{
+		{"TwoVars", "$: a = b + 1;\n$: b = a + 1;", "b → a → b"},
+		{"ThreeVars", "$: a = c;\n$: b = a;\n$: c = b;", "c → b → a → c"},
+		{"Statements", "$: { a = b; }\n$: if (a) { b = 1; }", "b → a → b"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(td.input))
+			if err == nil {
+				t.Fatalf("Expected %q to return an error", td.input)
+			}
+			if !strings.HasSuffix(err.Error(), td.cycle) {
+				t.Fatalf("Expected the error to name the cycle %q but got %q", td.cycle, err.Error())
+			}
+		})
+	}
+}
+
+func TestScriptNames(t *testing.T) {
+	script, err := Parse(strings.NewReader("import A, { b as c } from './a';\nexport let d;\nconst { e, f: [g] } = h;\nfunction i() {}\nclass J {}\n$: k = d * 2;\n$store;"))
+	if err != nil {
+		t.Fatalf("Parse return error: %q", err.Error())
+	}
+
+	expected := []string{"A", "c", "d", "e", "g", "i", "J", "k"}
+	if names := script.Names(); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected names %v but got %v", expected, names)
+	}
+}
+
+func TestFreeNames(t *testing.T) {
+	src := "import { a } from './a';\nfunction text(s) { return s; }\nfunction create_fragment(ctx) {\n\tlet t = space();\n\treturn { c() { t = text(element('p')); } };\n}\nclass C extends SvelteComponent {\n\tconstructor(options) { super(); init(this, options, null, create_fragment, a); }\n}\nexport default C;"
+	names, err := FreeNames([]byte(src))
+	if err != nil {
+		t.Fatalf("FreeNames return error: %q", err.Error())
+	}
+
+	expected := []string{"space", "element", "SvelteComponent", "init"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected free names %v but got %v", expected, names)
+	}
+}
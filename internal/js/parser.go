@@ -0,0 +1,1272 @@
+package js
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/tdewolff/parse/v2"
+	tjs "github.com/tdewolff/parse/v2/js"
+)
+
+// An astToken is a token of the js lexer, without the whitespace and comments
+// before it.
+type astToken struct {
+	tt         tjs.TokenType
+	data       []byte
+	start, end int
+	nl         bool // a line terminator is before the token
+}
+
+// astParser is a recursive descent parser that builds the ast, using the
+// tokens of the tdewolff js lexer. The lexer knows when a / starts a regexp
+// and keeps track of nested template literals, so the parser only has to keep
+// the byte offsets of the tokens.
+type astParser struct {
+	astToken
+	src     []byte
+	lex     *tjs.Lexer
+	offset  int
+	prevEnd int
+	peeked  *astToken
+
+	inFunc    bool
+	async     bool
+	generator bool
+}
+
+type astError struct {
+	err error
+}
+
+func newASTParser(src []byte) *astParser {
+	// The input appends a NULL to the bytes, so it gets a copy to not
+	// overwrite the byte after the slice.
+	buf := make([]byte, len(src), len(src)+1)
+	copy(buf, src)
+
+	p := &astParser{
+		src: src,
+		lex: tjs.NewLexer(parse.NewInputBytes(buf)),
+	}
+	p.next()
+	return p
+}
+
+// parseProgram parses the js as a list of statements.
+func parseProgram(src []byte) (prog *program, err error) {
+	defer recoverASTError(&err)
+
+	p := newASTParser(src)
+	prog = &program{}
+	for !p.atEnd() {
+		prog.body = append(prog.body, p.parseStatement())
+	}
+	prog.span = span{0, len(src)}
+	return prog, nil
+}
+
+// parseExpr parses the js as a single expression.
+func parseExpr(src []byte) (expr astNode, err error) {
+	defer recoverASTError(&err)
+
+	p := newASTParser(src)
+	expr = p.parseExpression(false)
+	if !p.atEnd() {
+		p.unexpected()
+	}
+	return expr, nil
+}
+
+// parseSnippet parses a piece of js from a script or template, which is
+// usually a list of statements but is tried as an expression first when it
+// starts with a brace, to not take an object literal for a block.
+func parseSnippet(src []byte) (*program, error) {
+	asExpr := func() (*program, error) {
+		expr, err := parseExpr(src)
+		if err != nil {
+			return nil, err
+		}
+		return &program{span{0, len(src)}, []astNode{&exprStmt{expr.pos(), expr}}}, nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimLeftFunc(src, unicode.IsSpace), []byte(curlyOpen)) {
+		if prog, err := asExpr(); err == nil {
+			return prog, nil
+		}
+		return parseProgram(src)
+	}
+
+	prog, err := parseProgram(src)
+	if err != nil {
+		if exprProg, exprErr := asExpr(); exprErr == nil {
+			return exprProg, nil
+		}
+	}
+	return prog, err
+}
+
+func recoverASTError(err *error) {
+	if r := recover(); r != nil {
+		astErr, ok := r.(astError)
+		if !ok {
+			panic(r)
+		}
+		*err = astErr.err
+	}
+}
+
+func (p *astParser) fail(format string, args ...interface{}) {
+	panic(astError{fmt.Errorf(format, args...)})
+}
+
+func (p *astParser) unexpected() {
+	if p.atEnd() {
+		p.fail("Unexpected end of js")
+	}
+	p.fail("Unexpected %q in js at %d", p.data, p.start)
+}
+
+func (p *astParser) lexNext() astToken {
+	nl := false
+	for {
+		tt, data := p.lex.Next()
+		start := p.offset
+		p.offset += len(data)
+
+		switch tt {
+		case tjs.WhitespaceToken, tjs.CommentToken:
+			continue
+		case tjs.LineTerminatorToken, tjs.CommentLineTerminatorToken:
+			nl = true
+			continue
+		case tjs.ErrorToken:
+			if err := p.lex.Err(); !errors.Is(err, io.EOF) {
+				panic(astError{err})
+			}
+			return astToken{tjs.ErrorToken, nil, len(p.src), len(p.src), true}
+		}
+		return astToken{tt, data, start, p.offset, nl}
+	}
+}
+
+func (p *astParser) next() {
+	p.prevEnd = p.end
+	if p.peeked != nil {
+		p.astToken, p.peeked = *p.peeked, nil
+		return
+	}
+	p.astToken = p.lexNext()
+}
+
+func (p *astParser) peek() astToken {
+	if p.peeked == nil {
+		tok := p.lexNext()
+		p.peeked = &tok
+	}
+	return *p.peeked
+}
+
+// regExp lexes the current / or /= token again as the start of a regexp.
+func (p *astParser) regExp() {
+	if p.peeked != nil {
+		p.fail("Can not lex a regexp after peeking in js at %d", p.start)
+	}
+
+	tt, data := p.lex.RegExp()
+	if tt == tjs.ErrorToken {
+		panic(astError{p.lex.Err()})
+	}
+	p.tt, p.data = tt, data
+	p.end = p.start + len(data)
+	p.offset = p.end
+}
+
+func (p *astParser) atEnd() bool {
+	return p.tt == tjs.ErrorToken
+}
+
+func (p *astParser) is(tt tjs.TokenType) bool {
+	return p.tt == tt
+}
+
+func (p *astParser) accept(tt tjs.TokenType) bool {
+	if p.tt != tt {
+		return false
+	}
+	p.next()
+	return true
+}
+
+func (p *astParser) expect(tt tjs.TokenType) {
+	if !p.accept(tt) {
+		p.unexpected()
+	}
+}
+
+func (p *astParser) span(start int) span {
+	return span{start, p.prevEnd}
+}
+
+// isIdent reports if the current token can be used as an identifier, await
+// and yield are only keywords inside of async functions and generators.
+func (p *astParser) isIdent() bool {
+	switch p.tt {
+	case tjs.AwaitToken:
+		return p.inFunc && !p.async
+	case tjs.YieldToken:
+		return !p.generator
+	}
+	return tjs.IsIdentifier(p.tt)
+}
+
+func (p *astParser) parseIdent() *identExpr {
+	if !p.isIdent() {
+		p.unexpected()
+	}
+	n := &identExpr{span{p.start, p.end}, string(p.data)}
+	p.next()
+	return n
+}
+
+func (p *astParser) consumeSemicolon() {
+	if p.accept(tjs.SemicolonToken) || p.is(tjs.CloseBraceToken) || p.atEnd() || p.nl {
+		return
+	}
+	p.unexpected()
+}
+
+// Statements
+
+func (p *astParser) parseStatement() astNode {
+	start := p.start
+	switch p.tt {
+	case tjs.OpenBraceToken:
+		return p.parseBlock()
+	case tjs.SemicolonToken:
+		p.next()
+		return &emptyStmt{p.span(start)}
+	case tjs.VarToken, tjs.ConstToken:
+		return p.parseVarStatement()
+	case tjs.LetToken:
+		if p.isLetDecl() {
+			return p.parseVarStatement()
+		}
+	case tjs.FunctionToken:
+		return p.parseFunction(true)
+	case tjs.AsyncToken:
+		if next := p.peek(); next.tt == tjs.FunctionToken && !next.nl {
+			return p.parseFunction(true)
+		}
+	case tjs.ClassToken:
+		return p.parseClass(true)
+	case tjs.IfToken:
+		p.next()
+		n := &ifStmt{test: p.parseParenExpr()}
+		n.cons = p.parseStatement()
+		if p.accept(tjs.ElseToken) {
+			n.alt = p.parseStatement()
+		}
+		n.span = p.span(start)
+		return n
+	case tjs.ForToken:
+		return p.parseFor()
+	case tjs.WhileToken:
+		p.next()
+		n := &whileStmt{test: p.parseParenExpr()}
+		n.body = p.parseStatement()
+		n.span = p.span(start)
+		return n
+	case tjs.DoToken:
+		p.next()
+		n := &whileStmt{body: p.parseStatement(), do: true}
+		p.expect(tjs.WhileToken)
+		n.test = p.parseParenExpr()
+		p.accept(tjs.SemicolonToken)
+		n.span = p.span(start)
+		return n
+	case tjs.ReturnToken, tjs.ThrowToken:
+		n := &jumpStmt{keyword: string(p.data)}
+		p.next()
+		if !p.nl && !p.is(tjs.SemicolonToken) && !p.is(tjs.CloseBraceToken) && !p.atEnd() {
+			n.arg = p.parseExpression(false)
+		}
+		p.consumeSemicolon()
+		n.span = p.span(start)
+		return n
+	case tjs.BreakToken, tjs.ContinueToken, tjs.DebuggerToken:
+		n := &jumpStmt{keyword: string(p.data)}
+		p.next()
+		if !p.nl && p.isIdent() && n.keyword != "debugger" {
+			n.label = p.parseIdent()
+		}
+		p.consumeSemicolon()
+		n.span = p.span(start)
+		return n
+	case tjs.SwitchToken:
+		return p.parseSwitch()
+	case tjs.TryToken:
+		return p.parseTry()
+	case tjs.WithToken:
+		p.next()
+		n := &withStmt{object: p.parseParenExpr()}
+		n.body = p.parseStatement()
+		n.span = p.span(start)
+		return n
+	case tjs.ImportToken:
+		if next := p.peek(); next.tt != tjs.OpenParenToken && next.tt != tjs.DotToken {
+			return p.parseImport()
+		}
+	case tjs.ExportToken:
+		return p.parseExport()
+	}
+
+	expr := p.parseExpression(false)
+	if ident, ok := expr.(*identExpr); ok && p.accept(tjs.ColonToken) {
+		body := p.parseStatement()
+		return &labeledStmt{p.span(start), ident, body}
+	}
+	p.consumeSemicolon()
+	return &exprStmt{p.span(start), expr}
+}
+
+// isLetDecl reports if the let is the start of a declaration, rather than a
+// variable named let.
+func (p *astParser) isLetDecl() bool {
+	next := p.peek()
+	switch next.tt {
+	case tjs.OpenBracketToken, tjs.OpenBraceToken:
+		return true
+	case tjs.InToken, tjs.InstanceofToken, tjs.OfToken:
+		return false
+	}
+	return tjs.IsIdentifier(next.tt) || next.tt == tjs.AwaitToken || next.tt == tjs.YieldToken
+}
+
+func (p *astParser) parseBlock() *blockStmt {
+	start := p.start
+	p.expect(tjs.OpenBraceToken)
+	n := &blockStmt{}
+	for !p.is(tjs.CloseBraceToken) {
+		if p.atEnd() {
+			p.unexpected()
+		}
+		n.body = append(n.body, p.parseStatement())
+	}
+	p.next()
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseParenExpr() astNode {
+	p.expect(tjs.OpenParenToken)
+	expr := p.parseExpression(false)
+	p.expect(tjs.CloseParenToken)
+	return expr
+}
+
+func (p *astParser) parseVarStatement() *varDecl {
+	n := p.parseVarDecl(false)
+	p.consumeSemicolon()
+	n.end = p.prevEnd
+	return n
+}
+
+func (p *astParser) parseVarDecl(noIn bool) *varDecl {
+	n := &varDecl{kind: string(p.data)}
+	start := p.start
+	p.next()
+	for {
+		declStart := p.start
+		d := &declarator{target: p.parseBindingTarget()}
+		if p.accept(tjs.EqToken) {
+			d.init = p.parseAssign(noIn)
+		}
+		d.span = p.span(declStart)
+		n.decls = append(n.decls, d)
+
+		if !p.accept(tjs.CommaToken) {
+			break
+		}
+	}
+	n.span = p.span(start)
+	return n
+}
+
+// parseBindingTarget parses an identifier, or an array or object pattern,
+// which are parsed like literals.
+func (p *astParser) parseBindingTarget() astNode {
+	switch p.tt {
+	case tjs.OpenBracketToken:
+		return p.parseArray()
+	case tjs.OpenBraceToken:
+		return p.parseObject()
+	}
+	return p.parseIdent()
+}
+
+func (p *astParser) parseFor() astNode {
+	start := p.start
+	p.next()
+	p.accept(tjs.AwaitToken)
+	p.expect(tjs.OpenParenToken)
+
+	var init astNode
+	switch {
+	case p.is(tjs.SemicolonToken):
+	case p.is(tjs.VarToken), p.is(tjs.ConstToken), p.is(tjs.LetToken) && p.isLetDecl():
+		init = p.parseVarDecl(true)
+	default:
+		init = p.parseExpression(true)
+	}
+
+	if p.is(tjs.InToken) || p.is(tjs.OfToken) {
+		n := &forInStmt{left: init, of: p.is(tjs.OfToken)}
+		p.next()
+		if n.of {
+			n.right = p.parseAssign(false)
+		} else {
+			n.right = p.parseExpression(false)
+		}
+		p.expect(tjs.CloseParenToken)
+		n.body = p.parseStatement()
+		n.span = p.span(start)
+		return n
+	}
+
+	n := &forStmt{init: init}
+	p.expect(tjs.SemicolonToken)
+	if !p.is(tjs.SemicolonToken) {
+		n.test = p.parseExpression(false)
+	}
+	p.expect(tjs.SemicolonToken)
+	if !p.is(tjs.CloseParenToken) {
+		n.update = p.parseExpression(false)
+	}
+	p.expect(tjs.CloseParenToken)
+	n.body = p.parseStatement()
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseSwitch() astNode {
+	start := p.start
+	p.next()
+	n := &switchStmt{disc: p.parseParenExpr()}
+	p.expect(tjs.OpenBraceToken)
+	for !p.accept(tjs.CloseBraceToken) {
+		caseStart := p.start
+		c := &switchCase{}
+		if p.accept(tjs.DefaultToken) {
+		} else if p.accept(tjs.CaseToken) {
+			c.test = p.parseExpression(false)
+		} else {
+			p.unexpected()
+		}
+		p.expect(tjs.ColonToken)
+		for !p.is(tjs.CaseToken) && !p.is(tjs.DefaultToken) && !p.is(tjs.CloseBraceToken) {
+			if p.atEnd() {
+				p.unexpected()
+			}
+			c.body = append(c.body, p.parseStatement())
+		}
+		c.span = p.span(caseStart)
+		n.cases = append(n.cases, c)
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseTry() astNode {
+	start := p.start
+	p.next()
+	n := &tryStmt{block: p.parseBlock()}
+	if p.accept(tjs.CatchToken) {
+		if p.accept(tjs.OpenParenToken) {
+			n.param = p.parseBindingTarget()
+			p.expect(tjs.CloseParenToken)
+		}
+		n.handler = p.parseBlock()
+	}
+	if p.accept(tjs.FinallyToken) {
+		n.finalizer = p.parseBlock()
+	}
+	if n.handler == nil && n.finalizer == nil {
+		p.unexpected()
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseModuleSource() string {
+	if !p.is(tjs.StringToken) {
+		p.unexpected()
+	}
+	source := string(p.data[1 : len(p.data)-1])
+	p.next()
+	return source
+}
+
+// parseModuleName parses the name of an import or export specifier, which can
+// be any identifier name or a string.
+func (p *astParser) parseModuleName() *identExpr {
+	if !tjs.IsIdentifierName(p.tt) && !p.is(tjs.StringToken) {
+		p.unexpected()
+	}
+	n := &identExpr{span{p.start, p.end}, string(p.data)}
+	p.next()
+	return n
+}
+
+// parseModuleSpecs parses the specifiers between braces, the name after as is
+// the local binding of an import and the exported name of an export.
+func (p *astParser) parseModuleSpecs(export bool) []*moduleSpec {
+	specs := []*moduleSpec{}
+	p.expect(tjs.OpenBraceToken)
+	for !p.accept(tjs.CloseBraceToken) {
+		start := p.start
+		name := p.parseModuleName()
+		spec := &moduleSpec{local: name, name: name.name}
+		if p.accept(tjs.AsToken) {
+			as := p.parseModuleName()
+			if export {
+				spec.name = as.name
+			} else {
+				spec.local = as
+			}
+		}
+		spec.span = p.span(start)
+		specs = append(specs, spec)
+
+		if !p.accept(tjs.CommaToken) && !p.is(tjs.CloseBraceToken) {
+			p.unexpected()
+		}
+	}
+	return specs
+}
+
+func (p *astParser) parseImport() astNode {
+	start := p.start
+	p.next()
+
+	n := &importDecl{}
+	if p.is(tjs.StringToken) {
+		n.source = p.parseModuleSource()
+		p.consumeSemicolon()
+		n.span = p.span(start)
+		return n
+	}
+
+	if p.isIdent() {
+		local := p.parseIdent()
+		n.specs = append(n.specs, &moduleSpec{local.span, local, "default"})
+		if !p.accept(tjs.CommaToken) {
+			p.expect(tjs.FromToken)
+			n.source = p.parseModuleSource()
+			p.consumeSemicolon()
+			n.span = p.span(start)
+			return n
+		}
+	}
+
+	if p.is(tjs.MulToken) {
+		specStart := p.start
+		p.next()
+		p.expect(tjs.AsToken)
+		local := p.parseIdent()
+		n.specs = append(n.specs, &moduleSpec{p.span(specStart), local, "*"})
+	} else {
+		n.specs = append(n.specs, p.parseModuleSpecs(false)...)
+	}
+
+	p.expect(tjs.FromToken)
+	n.source = p.parseModuleSource()
+	p.consumeSemicolon()
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseExport() astNode {
+	start := p.start
+	p.next()
+
+	n := &exportDecl{}
+	switch p.tt {
+	case tjs.DefaultToken:
+		p.next()
+		n.dflt = true
+		switch {
+		case p.is(tjs.FunctionToken), p.is(tjs.AsyncToken) && p.peek().tt == tjs.FunctionToken:
+			n.decl = p.parseFunction(true)
+		case p.is(tjs.ClassToken):
+			n.decl = p.parseClass(true)
+		default:
+			n.decl = p.parseAssign(false)
+			p.consumeSemicolon()
+		}
+	case tjs.MulToken:
+		p.next()
+		n.all = true
+		if p.accept(tjs.AsToken) {
+			name := p.parseModuleName()
+			n.specs = []*moduleSpec{{name.span, nil, name.name}}
+		}
+		p.expect(tjs.FromToken)
+		n.source = p.parseModuleSource()
+		p.consumeSemicolon()
+	case tjs.OpenBraceToken:
+		n.specs = p.parseModuleSpecs(true)
+		if p.accept(tjs.FromToken) {
+			n.source = p.parseModuleSource()
+		}
+		p.consumeSemicolon()
+	default:
+		n.decl = p.parseStatement()
+		switch n.decl.(type) {
+		case *varDecl:
+		case *funcExpr, *classExpr:
+		default:
+			p.fail("Invalid export declaration in js at %d", start)
+		}
+	}
+	n.span = p.span(start)
+	return n
+}
+
+// Functions and classes
+
+func (p *astParser) parseFunction(decl bool) *funcExpr {
+	n := &funcExpr{decl: decl}
+	start := p.start
+	if p.accept(tjs.AsyncToken) {
+		n.async = true
+	}
+	p.expect(tjs.FunctionToken)
+	if p.accept(tjs.MulToken) {
+		n.generator = true
+	}
+	if !p.is(tjs.OpenParenToken) {
+		n.name = p.parseIdent()
+	}
+
+	n.params, n.body = p.parseFunctionRest(n.async, n.generator)
+	n.span = p.span(start)
+	return n
+}
+
+// parseFunctionRest parses the params and body of a function or method.
+func (p *astParser) parseFunctionRest(async, generator bool) ([]astNode, astNode) {
+	inFunc, wasAsync, wasGenerator := p.inFunc, p.async, p.generator
+	p.inFunc, p.async, p.generator = true, async, generator
+	defer func() {
+		p.inFunc, p.async, p.generator = inFunc, wasAsync, wasGenerator
+	}()
+
+	params := []astNode{}
+	p.expect(tjs.OpenParenToken)
+	for !p.accept(tjs.CloseParenToken) {
+		params = append(params, p.parseElement())
+		if !p.accept(tjs.CommaToken) && !p.is(tjs.CloseParenToken) {
+			p.unexpected()
+		}
+	}
+	return params, p.parseBlock()
+}
+
+// parseElement parses an element of an array, or an argument or param, which
+// can be spread.
+func (p *astParser) parseElement() astNode {
+	start := p.start
+	if p.accept(tjs.EllipsisToken) {
+		arg := p.parseAssign(false)
+		return &spreadExpr{p.span(start), arg}
+	}
+	return p.parseAssign(false)
+}
+
+func (p *astParser) parseArrowBody(start int, params []astNode, async bool) *funcExpr {
+	inFunc, wasAsync, wasGenerator := p.inFunc, p.async, p.generator
+	p.inFunc, p.async, p.generator = true, async, false
+	defer func() {
+		p.inFunc, p.async, p.generator = inFunc, wasAsync, wasGenerator
+	}()
+
+	if p.nl {
+		p.unexpected()
+	}
+	p.expect(tjs.ArrowToken)
+
+	n := &funcExpr{params: params, arrow: true, async: async}
+	if p.is(tjs.OpenBraceToken) {
+		n.body = p.parseBlock()
+	} else {
+		n.body = p.parseAssign(false)
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseClass(decl bool) *classExpr {
+	start := p.start
+	p.next()
+
+	n := &classExpr{decl: decl}
+	if p.isIdent() {
+		n.name = p.parseIdent()
+	}
+	if p.accept(tjs.ExtendsToken) {
+		n.super = p.parseLeftHandSide()
+	}
+
+	p.expect(tjs.OpenBraceToken)
+	for !p.accept(tjs.CloseBraceToken) {
+		if p.accept(tjs.SemicolonToken) {
+			continue
+		}
+		n.members = append(n.members, p.parseClassMember())
+	}
+	n.span = p.span(start)
+	return n
+}
+
+// isModifier reports if the current get, set, async or static token is a
+// modifier of the method or field that follows, rather than its name.
+func (p *astParser) isModifier() bool {
+	next := p.peek()
+	switch next.tt {
+	case tjs.OpenParenToken, tjs.EqToken, tjs.SemicolonToken, tjs.CloseBraceToken,
+		tjs.CommaToken, tjs.ColonToken:
+		return false
+	}
+	return !(p.is(tjs.AsyncToken) && next.nl)
+}
+
+func (p *astParser) parseClassMember() *classMember {
+	start := p.start
+	m := &classMember{}
+	if p.is(tjs.StaticToken) && p.isModifier() {
+		p.next()
+		m.static = true
+
+		if p.is(tjs.OpenBraceToken) {
+			m.value = p.parseBlock()
+			m.span = p.span(start)
+			return m
+		}
+	}
+
+	async, generator, accessor := p.parseMethodModifiers()
+	m.key, m.computed = p.parsePropertyKey()
+	if p.is(tjs.OpenParenToken) || async || generator || accessor {
+		methodStart := p.start
+		params, body := p.parseFunctionRest(async, generator)
+		m.value = &funcExpr{span: p.span(methodStart), params: params, body: body, async: async, generator: generator}
+	} else {
+		if p.accept(tjs.EqToken) {
+			inFunc := p.inFunc
+			p.inFunc = true
+			m.value = p.parseAssign(false)
+			p.inFunc = inFunc
+		}
+		p.consumeSemicolon()
+	}
+	m.span = p.span(start)
+	return m
+}
+
+// parseMethodModifiers parses the async, * and get or set before the name of
+// a method.
+func (p *astParser) parseMethodModifiers() (async, generator, accessor bool) {
+	if p.is(tjs.AsyncToken) && p.isModifier() {
+		p.next()
+		async = true
+	}
+	if p.accept(tjs.MulToken) {
+		generator = true
+	}
+	if (p.is(tjs.GetToken) || p.is(tjs.SetToken)) && p.isModifier() {
+		p.next()
+		accessor = true
+	}
+	return
+}
+
+func (p *astParser) parsePropertyKey() (astNode, bool) {
+	start := p.start
+	switch {
+	case p.is(tjs.OpenBracketToken):
+		p.next()
+		key := p.parseAssign(false)
+		p.expect(tjs.CloseBracketToken)
+		return key, true
+	case tjs.IsIdentifierName(p.tt):
+		key := &identExpr{span{p.start, p.end}, string(p.data)}
+		p.next()
+		return key, false
+	case p.is(tjs.StringToken), tjs.IsNumeric(p.tt), p.is(tjs.PrivateIdentifierToken):
+		p.next()
+		return &literalExpr{p.span(start), string(p.src[start:p.prevEnd])}, false
+	}
+	p.unexpected()
+	return nil, false
+}
+
+// Expressions
+
+func (p *astParser) parseExpression(noIn bool) astNode {
+	start := p.start
+	expr := p.parseAssign(noIn)
+	if !p.is(tjs.CommaToken) {
+		return expr
+	}
+
+	seq := &seqExpr{exprs: []astNode{expr}}
+	for p.accept(tjs.CommaToken) {
+		seq.exprs = append(seq.exprs, p.parseAssign(noIn))
+	}
+	seq.span = p.span(start)
+	return seq
+}
+
+// arrowParams is the parenthesized list before an arrow, when it can't be an
+// expression.
+type arrowParams struct {
+	span
+	params []astNode
+}
+
+func isAssignOp(tt tjs.TokenType) bool {
+	switch tt {
+	case tjs.EqToken, tjs.AddEqToken, tjs.SubEqToken, tjs.MulEqToken, tjs.DivEqToken,
+		tjs.ModEqToken, tjs.ExpEqToken, tjs.LtLtEqToken, tjs.GtGtEqToken, tjs.GtGtGtEqToken,
+		tjs.BitAndEqToken, tjs.BitOrEqToken, tjs.BitXorEqToken, tjs.AndEqToken,
+		tjs.OrEqToken, tjs.NullishEqToken:
+		return true
+	}
+	return false
+}
+
+func (p *astParser) parseAssign(noIn bool) astNode {
+	start := p.start
+
+	if p.is(tjs.YieldToken) && p.generator {
+		p.next()
+		op := "yield"
+		if !p.nl && p.accept(tjs.MulToken) {
+			op = "yield*"
+		}
+		n := &unaryExpr{op: op}
+		if !p.nl && p.startsExpr() {
+			n.arg = p.parseAssign(noIn)
+		}
+		n.span = p.span(start)
+		return n
+	}
+
+	if p.is(tjs.AsyncToken) {
+		if next := p.peek(); !next.nl && (tjs.IsIdentifier(next.tt) || next.tt == tjs.YieldToken) {
+			p.next()
+			param := p.parseIdent()
+			return p.parseArrowBody(start, []astNode{param}, true)
+		}
+	}
+	if p.isIdent() && p.peek().tt == tjs.ArrowToken {
+		param := p.parseIdent()
+		return p.parseArrowBody(start, []astNode{param}, false)
+	}
+
+	left := p.parseConditional(noIn)
+	if p.is(tjs.ArrowToken) {
+		switch l := left.(type) {
+		case *arrowParams:
+			return p.parseArrowBody(start, l.params, false)
+		case *parenExpr:
+			params := []astNode{l.expr}
+			if seq, ok := l.expr.(*seqExpr); ok {
+				params = seq.exprs
+			}
+			return p.parseArrowBody(start, params, false)
+		case *callExpr:
+			if ident, ok := l.callee.(*identExpr); ok && ident.name == "async" && !l.optional && !l.isNew {
+				return p.parseArrowBody(start, l.args, true)
+			}
+		}
+		p.unexpected()
+	}
+	if _, ok := left.(*arrowParams); ok {
+		p.unexpected()
+	}
+
+	if isAssignOp(p.tt) {
+		op := string(p.data)
+		p.next()
+		value := p.parseAssign(noIn)
+		return &assignExpr{p.span(start), op, left, value}
+	}
+	return left
+}
+
+// startsExpr reports if the current token can start an expression.
+func (p *astParser) startsExpr() bool {
+	switch p.tt {
+	case tjs.CloseParenToken, tjs.CloseBracketToken, tjs.CloseBraceToken, tjs.CommaToken,
+		tjs.SemicolonToken, tjs.ColonToken, tjs.ErrorToken, tjs.TemplateMiddleToken, tjs.TemplateEndToken:
+		return false
+	}
+	return true
+}
+
+func (p *astParser) parseConditional(noIn bool) astNode {
+	start := p.start
+	test := p.parseBinary(1, noIn)
+	if !p.accept(tjs.QuestionToken) {
+		return test
+	}
+
+	cons := p.parseAssign(false)
+	p.expect(tjs.ColonToken)
+	alt := p.parseAssign(noIn)
+	return &condExpr{p.span(start), test, cons, alt}
+}
+
+func (p *astParser) binaryPrec(noIn bool) int {
+	switch p.tt {
+	case tjs.NullishToken:
+		return 1
+	case tjs.OrToken:
+		return 2
+	case tjs.AndToken:
+		return 3
+	case tjs.BitOrToken:
+		return 4
+	case tjs.BitXorToken:
+		return 5
+	case tjs.BitAndToken:
+		return 6
+	case tjs.EqEqToken, tjs.NotEqToken, tjs.EqEqEqToken, tjs.NotEqEqToken:
+		return 7
+	case tjs.LtToken, tjs.GtToken, tjs.LtEqToken, tjs.GtEqToken, tjs.InstanceofToken:
+		return 8
+	case tjs.InToken:
+		if noIn {
+			return 0
+		}
+		return 8
+	case tjs.LtLtToken, tjs.GtGtToken, tjs.GtGtGtToken:
+		return 9
+	case tjs.AddToken, tjs.SubToken:
+		return 10
+	case tjs.MulToken, tjs.DivToken, tjs.ModToken:
+		return 11
+	case tjs.ExpToken:
+		return 12
+	}
+	return 0
+}
+
+func (p *astParser) parseBinary(minPrec int, noIn bool) astNode {
+	start := p.start
+	left := p.parseUnary()
+	for {
+		prec := p.binaryPrec(noIn)
+		if prec == 0 || prec < minPrec {
+			return left
+		}
+
+		op := string(p.data)
+		p.next()
+		nextPrec := prec + 1
+		if op == "**" {
+			nextPrec = prec
+		}
+		right := p.parseBinary(nextPrec, noIn)
+		left = &binaryExpr{p.span(start), op, left, right}
+	}
+}
+
+func (p *astParser) parseUnary() astNode {
+	start := p.start
+	switch p.tt {
+	case tjs.NotToken, tjs.BitNotToken, tjs.AddToken, tjs.SubToken,
+		tjs.TypeofToken, tjs.VoidToken, tjs.DeleteToken:
+	case tjs.AwaitToken:
+		if p.isIdent() {
+			return p.parsePostfix()
+		}
+	case tjs.IncrToken, tjs.DecrToken:
+		op := string(p.data)
+		p.next()
+		arg := p.parseUnary()
+		return &updateExpr{p.span(start), op, true, arg}
+	default:
+		return p.parsePostfix()
+	}
+
+	op := string(p.data)
+	p.next()
+	arg := p.parseUnary()
+	return &unaryExpr{p.span(start), op, arg}
+}
+
+func (p *astParser) parsePostfix() astNode {
+	start := p.start
+	arg := p.parseLeftHandSide()
+	if (p.is(tjs.IncrToken) || p.is(tjs.DecrToken)) && !p.nl {
+		op := string(p.data)
+		p.next()
+		return &updateExpr{p.span(start), op, false, arg}
+	}
+	return arg
+}
+
+func (p *astParser) parseLeftHandSide() astNode {
+	start := p.start
+	var expr astNode
+	if p.is(tjs.NewToken) {
+		expr = p.parseNew()
+	} else {
+		expr = p.parsePrimary()
+	}
+	return p.parseCallOrMember(start, expr, true)
+}
+
+func (p *astParser) parseNew() astNode {
+	start := p.start
+	p.next()
+	if p.accept(tjs.DotToken) {
+		if !p.is(tjs.TargetToken) {
+			p.unexpected()
+		}
+		p.next()
+		return &literalExpr{p.span(start), string(p.src[start:p.prevEnd])}
+	}
+
+	var callee astNode
+	if p.is(tjs.NewToken) {
+		callee = p.parseNew()
+	} else {
+		callee = p.parsePrimary()
+	}
+	callee = p.parseCallOrMember(callee.pos().start, callee, false)
+
+	n := &callExpr{callee: callee, isNew: true}
+	if p.is(tjs.OpenParenToken) {
+		n.args = p.parseArgs()
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseArgs() []astNode {
+	args := []astNode{}
+	p.expect(tjs.OpenParenToken)
+	for !p.accept(tjs.CloseParenToken) {
+		args = append(args, p.parseElement())
+		if !p.accept(tjs.CommaToken) && !p.is(tjs.CloseParenToken) {
+			p.unexpected()
+		}
+	}
+	return args
+}
+
+func (p *astParser) parseMemberName() astNode {
+	if !tjs.IsIdentifierName(p.tt) && !p.is(tjs.PrivateIdentifierToken) {
+		p.unexpected()
+	}
+	n := &identExpr{span{p.start, p.end}, string(p.data)}
+	p.next()
+	return n
+}
+
+func (p *astParser) parseCallOrMember(start int, expr astNode, allowCall bool) astNode {
+	for {
+		switch {
+		case p.accept(tjs.DotToken):
+			prop := p.parseMemberName()
+			expr = &memberExpr{p.span(start), expr, prop, false, false}
+		case p.is(tjs.OptChainToken):
+			p.next()
+			switch {
+			case p.is(tjs.OpenParenToken):
+				args := p.parseArgs()
+				expr = &callExpr{p.span(start), expr, args, true, false}
+			case p.accept(tjs.OpenBracketToken):
+				prop := p.parseExpression(false)
+				p.expect(tjs.CloseBracketToken)
+				expr = &memberExpr{p.span(start), expr, prop, true, true}
+			default:
+				prop := p.parseMemberName()
+				expr = &memberExpr{p.span(start), expr, prop, false, true}
+			}
+		case p.accept(tjs.OpenBracketToken):
+			prop := p.parseExpression(false)
+			p.expect(tjs.CloseBracketToken)
+			expr = &memberExpr{p.span(start), expr, prop, true, false}
+		case p.is(tjs.OpenParenToken) && allowCall:
+			args := p.parseArgs()
+			expr = &callExpr{p.span(start), expr, args, false, false}
+		case p.is(tjs.TemplateToken), p.is(tjs.TemplateStartToken):
+			expr = p.parseTemplate(start, expr)
+		default:
+			return expr
+		}
+	}
+}
+
+func (p *astParser) parsePrimary() astNode {
+	start := p.start
+	switch {
+	case p.is(tjs.AsyncToken) && p.peek().tt == tjs.FunctionToken && !p.peek().nl:
+		return p.parseFunction(false)
+	case p.isIdent():
+		return p.parseIdent()
+	case p.is(tjs.FunctionToken):
+		return p.parseFunction(false)
+	case p.is(tjs.ClassToken):
+		return p.parseClass(false)
+	case p.is(tjs.DivToken), p.is(tjs.DivEqToken):
+		p.regExp()
+		p.next()
+		return &literalExpr{p.span(start), string(p.src[start:p.prevEnd])}
+	case p.is(tjs.TemplateToken), p.is(tjs.TemplateStartToken):
+		return p.parseTemplate(start, nil)
+	case p.is(tjs.OpenParenToken):
+		return p.parseParen()
+	case p.is(tjs.OpenBracketToken):
+		return p.parseArray()
+	case p.is(tjs.OpenBraceToken):
+		return p.parseObject()
+	case tjs.IsNumeric(p.tt), p.is(tjs.StringToken), p.is(tjs.PrivateIdentifierToken),
+		p.is(tjs.TrueToken), p.is(tjs.FalseToken), p.is(tjs.NullToken),
+		p.is(tjs.ThisToken), p.is(tjs.SuperToken), p.is(tjs.ImportToken):
+		p.next()
+		return &literalExpr{p.span(start), string(p.src[start:p.prevEnd])}
+	}
+	p.unexpected()
+	return nil
+}
+
+func (p *astParser) parseTemplate(start int, tag astNode) astNode {
+	n := &templateExpr{tag: tag}
+	if p.accept(tjs.TemplateToken) {
+		n.span = p.span(start)
+		return n
+	}
+
+	p.expect(tjs.TemplateStartToken)
+	for {
+		n.exprs = append(n.exprs, p.parseExpression(false))
+		if p.accept(tjs.TemplateEndToken) {
+			break
+		}
+		p.expect(tjs.TemplateMiddleToken)
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseParen() astNode {
+	start := p.start
+	p.expect(tjs.OpenParenToken)
+	if p.accept(tjs.CloseParenToken) {
+		return &arrowParams{p.span(start), []astNode{}}
+	}
+
+	exprs := []astNode{}
+	rest := false
+	for {
+		elemStart := p.start
+		if p.accept(tjs.EllipsisToken) {
+			arg := p.parseBindingTarget()
+			exprs = append(exprs, &spreadExpr{p.span(elemStart), arg})
+			rest = true
+		} else {
+			exprs = append(exprs, p.parseAssign(false))
+		}
+
+		if !p.accept(tjs.CommaToken) || p.is(tjs.CloseParenToken) {
+			break
+		}
+	}
+	p.expect(tjs.CloseParenToken)
+
+	if rest || p.is(tjs.ArrowToken) && !p.nl {
+		return &arrowParams{p.span(start), exprs}
+	}
+
+	var expr astNode = exprs[0]
+	if len(exprs) > 1 {
+		expr = &seqExpr{span{exprs[0].pos().start, exprs[len(exprs)-1].pos().end}, exprs}
+	}
+	return &parenExpr{p.span(start), expr}
+}
+
+func (p *astParser) parseArray() astNode {
+	start := p.start
+	p.expect(tjs.OpenBracketToken)
+	n := &arrayExpr{elems: []astNode{}}
+	for !p.accept(tjs.CloseBracketToken) {
+		if p.accept(tjs.CommaToken) {
+			n.elems = append(n.elems, nil)
+			continue
+		}
+
+		n.elems = append(n.elems, p.parseElement())
+		if !p.accept(tjs.CommaToken) && !p.is(tjs.CloseBracketToken) {
+			p.unexpected()
+		}
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseObject() astNode {
+	start := p.start
+	p.expect(tjs.OpenBraceToken)
+	n := &objectExpr{props: []*property{}}
+	for !p.accept(tjs.CloseBraceToken) {
+		n.props = append(n.props, p.parseProperty())
+		if !p.accept(tjs.CommaToken) && !p.is(tjs.CloseBraceToken) {
+			p.unexpected()
+		}
+	}
+	n.span = p.span(start)
+	return n
+}
+
+func (p *astParser) parseProperty() *property {
+	start := p.start
+	if p.accept(tjs.EllipsisToken) {
+		arg := p.parseAssign(false)
+		return &property{span: p.span(start), value: &spreadExpr{p.span(start), arg}}
+	}
+
+	prop := &property{}
+	isIdent := p.isIdent()
+	async, generator, accessor := p.parseMethodModifiers()
+	prop.key, prop.computed = p.parsePropertyKey()
+
+	switch {
+	case p.is(tjs.OpenParenToken) || async || generator || accessor:
+		methodStart := p.start
+		params, body := p.parseFunctionRest(async, generator)
+		prop.value = &funcExpr{span: p.span(methodStart), params: params, body: body, async: async, generator: generator}
+		prop.method = true
+	case p.accept(tjs.ColonToken):
+		prop.value = p.parseAssign(false)
+	case isIdent && !prop.computed:
+		ident := prop.key.(*identExpr)
+		prop.value = &identExpr{ident.span, ident.name}
+		prop.shorthand = true
+		if p.accept(tjs.EqToken) {
+			value := p.parseAssign(false)
+			prop.value = &assignExpr{p.span(start), "=", prop.value, value}
+		}
+	default:
+		p.unexpected()
+	}
+	prop.span = p.span(start)
+	return prop
+}
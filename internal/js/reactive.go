@@ -0,0 +1,114 @@
+package js
+
+import (
+	"errors"
+	"strings"
+)
+
+// reactiveDeps gets the root vars a reactive statement assigns and the ones
+// it depends on, which are the vars it references without assigning them.
+func (n *LabelNode) reactiveDeps() ([]string, []string) {
+	a := analyzeScopes(&program{n.stmt.span, []astNode{n.stmt.body}})
+
+	assignees := []string{}
+	isAssignee := map[string]bool{}
+	assign := func(scope *scope, target astNode) {
+		for _, ident := range assignedNames(target) {
+			if a.resolvesToRoot(scope, ident.name) && !isAssignee[ident.name] {
+				isAssignee[ident.name] = true
+				assignees = append(assignees, ident.name)
+			}
+		}
+	}
+	for _, as := range a.assigns {
+		assign(as.scope, as.expr.target)
+	}
+	for _, up := range a.updates {
+		assign(up.scope, up.expr.arg)
+	}
+
+	deps := []string{}
+	isDep := map[string]bool{}
+	for _, r := range a.rootRefs() {
+		if !isAssignee[r.ident.name] && !isDep[r.ident.name] {
+			isDep[r.ident.name] = true
+			deps = append(deps, r.ident.name)
+		}
+	}
+	return assignees, deps
+}
+
+// sortReactive orders the reactive statements so that every statement runs
+// after the statements that assign the vars it depends on, statements that
+// don't depend on each other keep their source order.
+func (n *Script) sortReactive() ([]*LabelNode, error) {
+	labels := []*LabelNode{}
+	deps := map[*LabelNode][]string{}
+	assignedBy := map[string][]*LabelNode{}
+	for _, r := range n.roots {
+		ln, ok := r.(*LabelNode)
+		if !ok || !ln.IsReactive() {
+			continue
+		}
+
+		assignees, lnDeps := ln.reactiveDeps()
+		for _, name := range assignees {
+			assignedBy[name] = append(assignedBy[name], ln)
+		}
+		labels = append(labels, ln)
+		deps[ln] = lnDeps
+	}
+
+	// the path has the statements that are being added, with the var that
+	// made the statement before it depend on it
+	type step struct {
+		label *LabelNode
+		via   string
+	}
+	path := []step{}
+	added := map[*LabelNode]bool{}
+	sorted := []*LabelNode{}
+
+	var add func(ln *LabelNode, via string) error
+	add = func(ln *LabelNode, via string) error {
+		for i, s := range path {
+			if s.label != ln {
+				continue
+			}
+
+			names := []string{}
+			for _, s := range path[i+1:] {
+				names = append(names, s.via)
+			}
+			names = append(names, via, names[0])
+			return errors.New("Cyclical dependency between reactive statements, " + strings.Join(names, " → "))
+		}
+		if added[ln] {
+			return nil
+		}
+
+		path = append(path, step{ln, via})
+		for _, name := range deps[ln] {
+			for _, other := range assignedBy[name] {
+				if other == ln {
+					continue
+				}
+				if err := add(other, name); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+
+		added[ln] = true
+		sorted = append(sorted, ln)
+		return nil
+	}
+
+	for _, ln := range labels {
+		if err := add(ln, ""); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
@@ -1,9 +1,6 @@
 package js
 
-import (
-	"bytes"
-	"unicode"
-)
+import "sort"
 
 type VarRewriter interface {
 	Rewrite([]byte) ([]byte, *VarsInfo)
@@ -33,12 +30,19 @@ func (info *VarsInfo) Names() []string {
 	return info.names
 }
 
-func (info *VarsInfo) Dirty() int {
-	dirty := 0
-	for _, varIndex := range info.indexes {
-		dirty += 1 << varIndex
+// Index returns the index of the named var.
+func (info *VarsInfo) Index(name string) (int, bool) {
+	for i, varName := range info.names {
+		if varName == name {
+			return info.indexes[i], true
+		}
 	}
-	return dirty
+	return -1, false
+}
+
+// Dirty returns the mask with the bits of the vars set.
+func (info *VarsInfo) Dirty() DirtyMask {
+	return NewDirtyMask(info.indexes...)
 }
 
 func (info *VarsInfo) insert(newVarIndex int, newVarName string) {
@@ -54,246 +58,386 @@ func (info *VarsInfo) insert(newVarIndex int, newVarName string) {
 	info.names = append(info.names, newVarName)
 }
 
+// dirtyChunkSize is the number of vars in each chunk of the dirty array the
+// runtime keeps of the changed vars.
+const dirtyChunkSize = 31
+
+// A DirtyMask has a bit set for each var, in chunks of 31 vars to match the
+// dirty array of the runtime.
+type DirtyMask []int
+
+// NewDirtyMask creates a mask with the bits of the var indexes set.
+func NewDirtyMask(indexes ...int) DirtyMask {
+	mask := DirtyMask{}
+	for _, i := range indexes {
+		chunk := i / dirtyChunkSize
+		for len(mask) <= chunk {
+			mask = append(mask, 0)
+		}
+		mask[chunk] |= 1 << (i % dirtyChunkSize)
+	}
+	return mask
+}
+
+// DirtyChunks gets the number of chunks the dirty array of the runtime needs
+// for the number of vars.
+func DirtyChunks(varCount int) int {
+	if varCount == 0 {
+		return 1
+	}
+	return (varCount + dirtyChunkSize - 1) / dirtyChunkSize
+}
+
+// Chunk gets the bits of the chunk, which are zero past the end of the mask.
+func (m DirtyMask) Chunk(i int) int {
+	if i >= len(m) {
+		return 0
+	}
+	return m[i]
+}
+
+// Or returns the mask with the bits of either mask set.
+func (m DirtyMask) Or(o DirtyMask) DirtyMask {
+	mask := DirtyMask{}
+	for i := 0; i < len(m) || i < len(o); i++ {
+		mask = append(mask, m.Chunk(i)|o.Chunk(i))
+	}
+	return mask
+}
+
+// AndNot returns the mask with the bits of the other mask cleared.
+func (m DirtyMask) AndNot(o DirtyMask) DirtyMask {
+	mask := DirtyMask{}
+	for i := range m {
+		mask = append(mask, m[i]&^o.Chunk(i))
+	}
+	return mask
+}
+
+// IsZero reports if no bits are set in the mask.
+func (m DirtyMask) IsZero() bool {
+	for _, chunk := range m {
+		if chunk != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 type RewriteFn func(int, string, Var, []byte) []byte
 
-type lexVarRewriter struct {
-	vars    []Var
-	fn      RewriteFn
-	lexInit func(lexFn) lexFn
-	hasVar  func([]byte, []byte) bool
+// astVarRewriter rewrites the js that references the root vars, using the
+// scopes of the parsed js so that params and shadowing declarations are left
+// alone.
+type astVarRewriter struct {
+	vars         []ctxVar
+	scoped       map[string]int
+	fn           RewriteFn
+	targets      func(*scopeAnalysis) []rewriteTarget
+	setsStores   bool
+	resubscribes map[string]bool // the stores subscribed to again when assigned
+}
+
+// A rewriteTarget is the part of the js that gets rewritten for a reference
+// to a var.
+type rewriteTarget struct {
+	span
+	name      string
+	shorthand bool
+	pattern   []string // the names assigned by a destructuring assignment
+	value     bool     // the new value is the var, and not the returned value
+	rebinds   bool     // the var is assigned, and not mutated
+}
+
+// mutatingMethods are the array methods that change the array they're called
+// on.
+var mutatingMethods = map[string]bool{
+	"push":       true,
+	"pop":        true,
+	"shift":      true,
+	"unshift":    true,
+	"splice":     true,
+	"sort":       true,
+	"reverse":    true,
+	"fill":       true,
+	"copyWithin": true,
 }
 
 func NewAssignmentRewriter(s *Script, fn RewriteFn) VarRewriter {
-	rootVars := []Var{}
+	ctxVars := []ctxVar{}
+	resubscribes := map[string]bool{}
 	if s != nil {
-		rootVars = s.rootVars()
+		ctxVars = s.ctxVars()
+		for _, store := range s.RebindableStores() {
+			resubscribes[store] = true
+		}
 	}
 
-	return &lexVarRewriter{
-		rootVars,
-		fn,
-		lexRewriteAssignments,
-		func(data, name []byte) bool {
-			if !bytes.HasPrefix(data, name) {
-				return false
-			}
-			if len(data) == len(name) {
-				return true
+	return &astVarRewriter{
+		vars:         ctxVars,
+		scoped:       map[string]int{},
+		fn:           fn,
+		resubscribes: resubscribes,
+		targets: func(a *scopeAnalysis) []rewriteTarget {
+			targets := []rewriteTarget{}
+			for _, as := range a.assigns {
+				switch target := unparen(as.expr.target).(type) {
+				case *identExpr:
+					if a.resolvesToRoot(as.scope, target.name) {
+						targets = append(targets, rewriteTarget{span: as.expr.span, name: target.name, rebinds: true})
+					}
+				case *memberExpr:
+					if root := memberRoot(target); root != nil && a.resolvesToRoot(as.scope, root.name) {
+						targets = append(targets, rewriteTarget{span: as.expr.span, name: root.name, value: true})
+					}
+				case *arrayExpr, *objectExpr:
+					names := []string{}
+					for _, ident := range assignedNames(target) {
+						if a.resolvesToRoot(as.scope, ident.name) {
+							names = append(names, ident.name)
+						}
+					}
+					if as.expr.op == "=" && len(names) != 0 {
+						targets = append(targets, rewriteTarget{span: as.expr.span, pattern: names})
+					}
+				}
 			}
-
-			rest := data[len(name):]
-			switch {
-			case unicode.IsSpace(rune(rest[0])):
-				return true
-			case bytes.HasPrefix(rest, []byte(eqOp)):
-				return true
-			case bytes.HasPrefix(rest, []byte(plusEqOp)):
-				return true
-			case bytes.HasPrefix(rest, []byte(minusEqOp)):
-				return true
+			for _, up := range a.updates {
+				switch arg := unparen(up.expr.arg).(type) {
+				case *identExpr:
+					if a.resolvesToRoot(up.scope, arg.name) {
+						targets = append(targets, rewriteTarget{span: up.expr.span, name: arg.name, value: !up.expr.prefix})
+					}
+				case *memberExpr:
+					if root := memberRoot(arg); root != nil && a.resolvesToRoot(up.scope, root.name) {
+						targets = append(targets, rewriteTarget{span: up.expr.span, name: root.name, value: true})
+					}
+				}
 			}
-
-			return false
+			return targets
 		},
+		setsStores: true,
 	}
 }
 
 func NewVarNameRewriter(s *Script, fn RewriteFn) VarRewriter {
-	rootVars := []Var{}
+	ctxVars := []ctxVar{}
 	if s != nil {
-		rootVars = s.rootVars()
+		ctxVars = s.ctxVars()
 	}
 
-	return &lexVarRewriter{
-		rootVars,
-		fn,
-		lexRewriteVarNames,
-		func(data, name []byte) bool {
-			return bytes.Compare(data, name) == 0
+	return &astVarRewriter{
+		vars:   ctxVars,
+		scoped: map[string]int{},
+		fn:     fn,
+		targets: func(a *scopeAnalysis) []rewriteTarget {
+			targets := []rewriteTarget{}
+			for _, r := range a.rootRefs() {
+				targets = append(targets, rewriteTarget{span: r.ident.span, name: r.ident.name, shorthand: r.shorthand})
+			}
+			return targets
 		},
 	}
 }
 
-func (rw *lexVarRewriter) Rewrite(data []byte) ([]byte, *VarsInfo) {
-	lex := startNewLexer(rw.lexInit, data)
-	info := NewEmptyVarsInfo()
-	newData := rewriteParser(lex, func(currData []byte) []byte {
-		i := -1
-		for _, v := range rw.vars {
-			for _, name := range v.VarNames() {
-				i += 1
-				if !rw.hasVar(currData, []byte(name)) {
-					continue
-				}
-
-				info.insert(i, name)
+// WithScopedVars creates a copy of the rewriter that also rewrites the vars of
+// an inner scope, which shadow the root vars and have the given indexes.
+func WithScopedVars(rw VarRewriter, vars map[string]int) VarRewriter {
+	arw, ok := rw.(*astVarRewriter)
+	if !ok {
+		return rw
+	}
 
-				if rw.fn == nil {
-					return currData
-				}
-				return rw.fn(i, name, v, currData)
-			}
-		}
-		return currData
-	})
+	scoped := map[string]int{}
+	for name, i := range arw.scoped {
+		scoped[name] = i
+	}
+	for name, i := range vars {
+		scoped[name] = i
+	}
 
-	return newData, info
+	newRw := *arw
+	newRw.scoped = scoped
+	return &newRw
 }
 
-// lexAssignments will tokenize a javascript block (as output by lexScript) to find assignments.
-func lexRewriteAssignments(lastLex lexFn) lexFn {
-	var lexRewriteAssignmentsFunc lexFn
-	lexRewriteAssignmentsFunc = func(lex *codeLexer) lexFn {
-		acceeptAndEmitAssignment := func() bool {
-			currPos := lex.nextPos
-			if !lex.acceptVarName() {
-				return false
+// WithMutatingCalls creates a copy of the assignment rewriter that also
+// invalidates the vars that mutating array methods, like push, are called on.
+func WithMutatingCalls(rw VarRewriter) VarRewriter {
+	arw, ok := rw.(*astVarRewriter)
+	if !ok || !arw.setsStores {
+		return rw
+	}
+
+	newRw := *arw
+	newRw.targets = func(a *scopeAnalysis) []rewriteTarget {
+		targets := arw.targets(a)
+		for _, c := range a.calls {
+			callee, ok := unparen(c.expr.callee).(*memberExpr)
+			if !ok || callee.computed || c.expr.isNew {
+				continue
+			}
+			if method, ok := callee.property.(*identExpr); !ok || !mutatingMethods[method.name] {
+				continue
 			}
 
-			lex.acceptSpaces()
-			switch {
-			case lex.acceptExact(plusEqOp):
-				break
-			case lex.acceptExact(minusEqOp):
-				break
-			case lex.acceptExact(eqOp) && !lex.acceptExact(eqOp):
-				break
-			default:
-				lex.nextPos = currPos
-				return false
+			root := memberRoot(callee)
+			if root != nil && a.resolvesToRoot(c.scope, root.name) {
+				targets = append(targets, rewriteTarget{span: c.expr.span, name: root.name, value: true})
 			}
+		}
+		return targets
+	}
+	return &newRw
+}
 
-			lex.acceptCodeBlock()
-			assignPos := lex.nextPos
+// lookup finds the index of the named var, and the root var that declares it
+// when it isn't a scoped var.
+func (rw *astVarRewriter) lookup(name string) (int, Var, bool) {
+	if i, ok := rw.scoped[name]; ok {
+		return i, nil, true
+	}
 
-			lex.nextPos = currPos
-			lex.emit(fragmentType)
+	for i, cv := range rw.vars {
+		if cv.name == name {
+			return i, cv.v, true
+		}
+	}
+	return -1, nil, false
+}
 
-			lex.nextPos = assignPos
-			lex.emit(targetType)
-			return true
+func (rw *astVarRewriter) Rewrite(data []byte) ([]byte, *VarsInfo) {
+	info := NewEmptyVarsInfo()
+	prog, err := parseSnippet(data)
+	if err != nil {
+		return data, info
+	}
 
+	edits := []jsEdit{}
+	for _, t := range rw.targets(analyzeScopes(prog)) {
+		t := t
+		if t.pattern != nil {
+			vars := []patternVar{}
+			for _, name := range t.pattern {
+				if i, v, ok := rw.lookup(name); ok {
+					info.insert(i, name)
+					vars = append(vars, patternVar{i, name, v})
+				}
+			}
+			if len(vars) == 0 {
+				continue
+			}
+
+			edits = append(edits, jsEdit{t.span, func(currData []byte) []byte {
+				return rw.rewritePattern(currData, vars)
+			}})
+			continue
 		}
 
-		var skpr skipper
-		switch {
-		case lex.atEnd():
-			lex.emit(fragmentType)
-			return lastLex
-		case lex.acceptExact(dotOp), lex.acceptExact(optnlDotOp):
-			lex.acceptSpaces()
-			lex.acceptVarName()
-			return lexRewriteAssignmentsFunc
-		case lex.acceptExact(lineCommentOpen):
-			skpr = newLineCommentSkipper()
-		case lex.acceptExact(blockCommentOpen):
-			skpr = newBlockCommentSkipper()
-		case lex.acceptExact(singleQuote):
-			skpr = newSingleQuoteSkipper()
-		case lex.acceptExact(doubleQuote):
-			skpr = newDoubleQuoteSkipper()
-		case lex.acceptExact(tmplQuote):
-			skpr = newTmplQuoteSkipper()
-		case lex.acceptExact(regexQuote):
-			skpr = newRegexQuoteSkipper()
-		default:
-			if !acceeptAndEmitAssignment() {
-				lex.pop()
-			}
-			return lexRewriteAssignmentsFunc
+		i, v, ok := rw.lookup(t.name)
+		_, isScoped := rw.scoped[t.name]
+		resubscribe := t.rebinds && !isScoped && rw.resubscribes[t.name]
+		if !ok && !resubscribe {
+			continue
+		}
+		if ok {
+			info.insert(i, t.name)
 		}
 
-		lex.skip(skpr, func(_ byte) {
-			switch open, _ := skpr.group(); string(open) {
-			case lineCommentOpen, blockCommentOpen, singleQuote, doubleQuote, tmplQuote, regexQuote:
-				return
+		edits = append(edits, jsEdit{t.span, func(currData []byte) []byte {
+			if ok {
+				currData = rw.rewriteVar(t, i, v, currData)
 			}
-
-			acceeptAndEmitAssignment()
-		})
-		return lexRewriteAssignmentsFunc
+			if resubscribe {
+				return []byte("$$subscribe_" + t.name + "(" + string(currData) + ")")
+			}
+			return currData
+		}})
 	}
-	return lexRewriteAssignmentsFunc
+
+	return applyEdits(data, span{0, len(data)}, edits), info
 }
 
-// lexRewriteVarNames will tokenize a javascript block (as output by lexScript) to find variable names (and keywords).
-func lexRewriteVarNames(lastLex lexFn) lexFn {
-	var lexRewriteVarNamesFunc lexFn
-	lexRewriteVarNamesFunc = func(lex *codeLexer) lexFn {
-		acceeptAndEmitVarName := func() bool {
-			currPos := lex.nextPos
-			if !lex.acceptVarName() {
-				return false
-			}
-			varPos := lex.nextPos
+// rewriteVar rewrites the js of the target that references the var.
+func (rw *astVarRewriter) rewriteVar(t rewriteTarget, i int, v Var, data []byte) []byte {
+	if sv, ok := v.(*StoreVar); ok && rw.setsStores {
+		return []byte("set_store_value(" + sv.store + ", " + string(data) + ", " + t.name + ")")
+	}
+	if rw.fn == nil {
+		return data
+	}
 
-			lex.nextPos = currPos
-			lex.emit(fragmentType)
+	if t.value {
+		data = []byte(string(data) + ", " + t.name)
+	}
+	newData := rw.fn(i, t.name, v, data)
+	if t.shorthand {
+		return append([]byte(t.name+": "), newData...)
+	}
+	return newData
+}
 
-			lex.nextPos = varPos
-			lex.emit(targetType)
-			return true
-		}
+// A jsEdit replaces a span of the js with the result of its function, which
+// gets the js of the span with the edits inside of it applied.
+type jsEdit struct {
+	span
+	fn func([]byte) []byte
+}
 
-		switch {
-		case lex.atEnd():
-			lex.emit(fragmentType)
-			return lastLex
-		case lex.acceptExact(dotOp), lex.acceptExact(optnlDotOp):
-			lex.acceptSpaces()
-			lex.acceptVarName()
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(lineCommentOpen):
-			lex.skip(newLineCommentSkipper(), nil)
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(blockCommentOpen):
-			lex.skip(newBlockCommentSkipper(), nil)
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(singleQuote):
-			lex.skip(newSingleQuoteSkipper(), nil)
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(doubleQuote):
-			lex.skip(newDoubleQuoteSkipper(), nil)
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(tmplQuote):
-			skpr := newTmplQuoteSkipper()
-			lex.skip(skpr, func(_ byte) {
-				switch open, _ := skpr.group(); string(open) {
-				case parenOpen, curlyOpen, tmplQuoteExprOpen:
-					lex.backup()
-					if !acceeptAndEmitVarName() {
-						lex.pop()
-					}
-				}
-			})
-			return lexRewriteVarNamesFunc
-		case lex.acceptExact(regexQuote):
-			lex.skip(newRegexQuoteSkipper(), nil)
-			return lexRewriteVarNamesFunc
+// applyEdits applies the edits inside of the span of the data, edits can be
+// nested but must not overlap otherwise.
+func applyEdits(data []byte, s span, edits []jsEdit) []byte {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
 		}
+		return edits[i].end > edits[j].end
+	})
 
-		if !acceeptAndEmitVarName() {
-			lex.pop()
+	newData := []byte{}
+	pos := s.start
+	for i := 0; i < len(edits); {
+		e := edits[i]
+		j := i + 1
+		for j < len(edits) && edits[j].start < e.end {
+			j++
 		}
-		return lexRewriteVarNamesFunc
+
+		newData = append(newData, data[pos:e.start]...)
+		newData = append(newData, e.fn(applyEdits(data, e.span, edits[i+1:j]))...)
+		pos = e.end
+		i = j
 	}
-	return lexRewriteVarNamesFunc
+	return append(newData, data[pos:s.end]...)
 }
 
-// rewriteParser will call the rewriteFunc for every rewrite target emited by the lexer, and merge the returned data.
-func rewriteParser(lex *lexer, rw func([]byte) []byte) []byte {
-	rwData := [][]byte{}
-	for tt, data := lex.Next(); tt != eofType; {
-		switch tt {
-		case fragmentType, commentType:
-			rwData = append(rwData, data)
-		case targetType:
-			rwData = append(rwData, rw(data))
-		default:
-			panic("Invalid token type emited from lexFn for rewriteParser")
-		}
+// A patternVar is a var that is assigned by a destructuring assignment.
+type patternVar struct {
+	i    int
+	name string
+	v    Var
+}
 
-		tt, data = lex.Next()
+// rewritePattern invalidates all the vars of a destructuring assignment, the
+// first var is invalidated with the assignment as the returned value, like
+// `$$invalidate(0, {a, b} = obj, a, $$invalidate(1, b))`, and the others are
+// extra args that are evaluated after the assignment.
+func (rw *astVarRewriter) rewritePattern(data []byte, vars []patternVar) []byte {
+	rest := ""
+	for _, pv := range vars[1:] {
+		rest += ", " + string(rw.invalidate(pv, []byte(pv.name), pv.name))
+	}
+	return rw.invalidate(vars[0], data, vars[0].name+rest)
+}
+
+// invalidate wraps the returned js in the invalidation of the var, with the
+// value js as the new value of the var.
+func (rw *astVarRewriter) invalidate(pv patternVar, ret []byte, value string) []byte {
+	if sv, ok := pv.v.(*StoreVar); ok && rw.setsStores {
+		return []byte("set_store_value(" + sv.store + ", " + string(ret) + ", " + value + ")")
+	}
+	if rw.fn == nil {
+		return ret
 	}
-	return bytes.Join(rwData, nil)
+	return rw.fn(pv.i, pv.name, pv.v, []byte(string(ret)+", "+value))
 }
@@ -2,9 +2,22 @@ package js
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 )
 
+// parseTestScript parses the script of a component whose template has the
+// given js.
+func parseTestScript(t *testing.T, src string, tmpl string) *Script {
+	s, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse return error: %q", err.Error())
+	}
+	s.UseRefs([]byte(tmpl))
+	return s
+}
+
 func TestLexRewriteAssignment(t *testing.T) {
 	testRewriteValue := []byte("REWRITTEN")
 	testData := []struct {
@@ -22,8 +35,10 @@ func TestLexRewriteAssignment(t *testing.T) {
 		{
 			"DotAssignment",
 			[]byte("value.test = 'test';"),
-			[][]byte{},
-			[]byte("value.test = 'test';"),
+			[][]byte{
+				[]byte("value.test = 'test', value"),
+			},
+			[]byte("REWRITTEN;"),
 		},
 		{
 			"SingleAssignment",
@@ -84,10 +99,7 @@ func TestLexRewriteAssignment(t *testing.T) {
 	for _, td := range testData {
 		td := td
 		t.Run(td.name, func(t *testing.T) {
-			s := &Script{[]Node{
-				&VarNode{[]byte("let"), []byte(" value"), nil, nil, nil, nil},
-				&VarNode{[]byte("let"), []byte(" another"), nil, nil, nil, nil},
-			}}
+			s := parseTestScript(t, "let value;\nlet another;", "value + another")
 
 			foundTargets := [][]byte{}
 			rw := NewAssignmentRewriter(s, func(_ int, name string, _ Var, data []byte) []byte {
@@ -164,6 +176,33 @@ func TestLexRewriteVarNames(t *testing.T) {
 			},
 			[]byte("`${REWRITTEN + ' ' + REWRITTEN}!`"),
 		},
+		{
+			"DivisionWithVarNames",
+			[]byte("value / another / 2"),
+			[][]byte{
+				[]byte("value"),
+				[]byte("another"),
+			},
+			[]byte("REWRITTEN / REWRITTEN / 2"),
+		},
+		{
+			"RegexWithVarName",
+			[]byte("/value/.test(another) && value"),
+			[][]byte{
+				[]byte("another"),
+				[]byte("value"),
+			},
+			[]byte("/value/.test(REWRITTEN) && REWRITTEN"),
+		},
+		{
+			"NestedTmplStringWithVarName",
+			[]byte("`a ${`b ${value / 2}`} ${another}`"),
+			[][]byte{
+				[]byte("value"),
+				[]byte("another"),
+			},
+			[]byte("`a ${`b ${REWRITTEN / 2}`} ${REWRITTEN}`"),
+		},
 		{
 			"LabeledBlockWithVarName",
 			[]byte(
@@ -188,10 +227,7 @@ func TestLexRewriteVarNames(t *testing.T) {
 	for _, td := range testData {
 		td := td
 		t.Run(td.name, func(t *testing.T) {
-			s := &Script{[]Node{
-				&VarNode{[]byte("let"), []byte(" value"), nil, nil, nil, nil},
-				&VarNode{[]byte("let"), []byte(" another"), nil, nil, nil, nil},
-			}}
+			s := parseTestScript(t, "let value;\nlet another;", "value + another")
 
 			foundTargets := [][]byte{}
 			rw := NewVarNameRewriter(s, func(_ int, name string, _ Var, data []byte) []byte {
@@ -219,3 +255,366 @@ func TestLexRewriteVarNames(t *testing.T) {
 		})
 	}
 }
+
+func TestRewriteScopedVarNames(t *testing.T) {
+	s := parseTestScript(t, "let value;\nlet another;", "value + another")
+
+	rw := WithScopedVars(
+		NewVarNameRewriter(s, func(i int, name string, _ Var, _ []byte) []byte {
+			return []byte(fmt.Sprintf("%s[%d]", name, i))
+		}),
+		map[string]int{"item": 2, "another": 3},
+	)
+	result, info := rw.Rewrite([]byte("value + item + another + skip"))
+
+	expected := []byte("value[0] + item[2] + another[3] + skip")
+	if bytes.Compare(expected, result) != 0 {
+		t.Fatalf("Expected result to be %q but got %q", expected, result)
+	}
+	if dirty := info.Dirty(); len(dirty) != 1 || dirty[0] != 13 {
+		t.Fatalf("Expected dirty to be %v but got %v", DirtyMask{13}, dirty)
+	}
+}
+
+func TestRewriteScopes(t *testing.T) {
+	testData := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"Param", "function f(value) { return value + another; }", "function f(value) { return value + another[1]; }"},
+		{"ArrowParam", "items.map(value => value + another)", "items.map(value => value + another[1])"},
+		{"ShadowingLet", "if (ok) { let value = 1; value = another; }", "if (ok) { let value = 1; value = another[1]; }"},
+		{"HoistedVar", "function f() { value = 1; var value; }", "function f() { value = 1; var value; }"},
+		{"CatchParam", "try { f() } catch (value) { another = value }", "try { f() } catch (value) { another[1] = value }"},
+		{"ObjectKeys", "({ value: another, another: 1 }).value", "({ value: another[1], another: 1 }).value"},
+		{"Shorthand", "({ value, another })", "({ value: value[0], another: another[1] })"},
+		{"ShadowedShorthand", "(value) => ({ value, another })", "(value) => ({ value, another: another[1] })"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			s := parseTestScript(t, "let value;\nlet another;", "value + another")
+
+			rw := NewVarNameRewriter(s, func(i int, name string, _ Var, _ []byte) []byte {
+				return []byte(fmt.Sprintf("%s[%d]", name, i))
+			})
+			result, _ := rw.Rewrite([]byte(td.input))
+			if string(result) != td.output {
+				t.Fatalf("Expected result to be %q but got %q", td.output, result)
+			}
+		})
+	}
+}
+
+func TestRewriteScopedAssignments(t *testing.T) {
+	s := parseTestScript(t, "let value;", "value")
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+	result, _ := rw.Rewrite([]byte("function f(value) { value = 1; }\nfunction g() { value = 2; }\n({ value = 3 } = obj);"))
+
+	expected := []byte("function f(value) { value = 1; }\nfunction g() { $$invalidate(0, value = 2); }\n($$invalidate(0, { value = 3 } = obj, value));")
+	if bytes.Compare(expected, result) != 0 {
+		t.Fatalf("Expected result to be %q but got %q", expected, result)
+	}
+}
+
+func TestRewriteAssignmentOperators(t *testing.T) {
+	s := parseTestScript(t, "let count = writable(0);\nlet x = 1;", "$count + x")
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+
+	testData := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"Postfix", "x++;", "$$invalidate(1, x++, x);"},
+		{"PostfixValue", "y = x--;", "y = $$invalidate(1, x--, x);"},
+		{"Prefix", "y = ++x;", "y = $$invalidate(1, ++x);"},
+		{"PrefixDecrement", "--x;", "$$invalidate(1, --x);"},
+		{"Parenthesized", "(x)++;", "$$invalidate(1, (x)++, x);"},
+		{"StorePostfix", "$count++;", "set_store_value(count, $count++, $count);"},
+		{"LocalUpdate", "for (let x = 0; x < 2; x++) {}", "for (let x = 0; x < 2; x++) {}"},
+		{"MemberUpdate", "y.x++;", "y.x++;"},
+		{"RootMemberUpdate", "++x.n;", "$$invalidate(1, ++x.n, x);"},
+	}
+	for _, op := range []string{"*=", "/=", "%=", "**=", "<<=", ">>=", ">>>=", "&=", "|=", "^=", "&&=", "||=", "??="} {
+		testData = append(testData, struct {
+			name   string
+			input  string
+			output string
+		}{op, "x " + op + " 2;", "$$invalidate(1, x " + op + " 2);"})
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			result, _ := rw.Rewrite([]byte(td.input))
+			if string(result) != td.output {
+				t.Fatalf("Expected result to be %q but got %q", td.output, result)
+			}
+		})
+	}
+}
+
+func TestRewriteMemberAssignments(t *testing.T) {
+	s := parseTestScript(t, "let count = writable({});\nlet obj = {}, arr = [];", "[$count, obj, arr]")
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+	mrw := WithMutatingCalls(rw)
+
+	testData := []struct {
+		name   string
+		rw     VarRewriter
+		input  string
+		output string
+	}{
+		{"Field", rw, "obj.field = 1;", "$$invalidate(1, obj.field = 1, obj);"},
+		{"Index", rw, "arr[i] = v;", "$$invalidate(2, arr[i] = v, arr);"},
+		{"Nested", rw, "obj.address.city = v;", "$$invalidate(1, obj.address.city = v, obj);"},
+		{"Operator", rw, "obj.n += 1;", "$$invalidate(1, obj.n += 1, obj);"},
+		{"Store", rw, "$count.n = 1;", "set_store_value(count, $count.n = 1, $count);"},
+		{"Pattern", rw, "[obj.a, arr[0]] = v;", "$$invalidate(1, [obj.a, arr[0]] = v, obj, $$invalidate(2, arr, arr));"},
+		{"LocalObject", rw, "function f(obj) { obj.field = 1; }", "function f(obj) { obj.field = 1; }"},
+		{"NotRootObject", rw, "get().field = 1; this.field = 1;", "get().field = 1; this.field = 1;"},
+		{"CallsNotOptedIn", rw, "arr.push(1);", "arr.push(1);"},
+		{"Push", mrw, "arr.push(1);", "$$invalidate(2, arr.push(1), arr);"},
+		{"Splice", mrw, "n = obj.list.splice(0, 1);", "n = $$invalidate(1, obj.list.splice(0, 1), obj);"},
+		{"Sort", mrw, "arr.sort((a, b) => a - b);", "$$invalidate(2, arr.sort((a, b) => a - b), arr);"},
+		{"OtherMethod", mrw, "arr.map(f); arr['push'](1);", "arr.map(f); arr['push'](1);"},
+		{"LocalArray", mrw, "{ let arr = []; arr.push(1); }", "{ let arr = []; arr.push(1); }"},
+		{"AssignmentsStillRewritten", mrw, "obj = {};", "$$invalidate(1, obj = {});"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			result, _ := td.rw.Rewrite([]byte(td.input))
+			if string(result) != td.output {
+				t.Fatalf("Expected result to be %q but got %q", td.output, result)
+			}
+		})
+	}
+}
+
+func TestRewriteDestructuringAssignments(t *testing.T) {
+	s := parseTestScript(t, "let count = writable(0);\nlet a, b;\nlet c = 1;", "[$count, a, b, c]")
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+
+	testData := []struct {
+		name   string
+		input  string
+		output string
+	}{
+		{"Object", "({ a, b } = obj);", "($$invalidate(1, { a, b } = obj, a, $$invalidate(2, b, b)));"},
+		{"Array", "[a, b] = [b, a];", "$$invalidate(1, [a, b] = [b, a], a, $$invalidate(2, b, b));"},
+		{"DefaultsAndRest", "[a = c, ...b] = arr;", "$$invalidate(1, [a = c, ...b] = arr, a, $$invalidate(2, b, b));"},
+		{"Store", "[$count, c] = arr;", "set_store_value(count, [$count, c] = arr, $count, $$invalidate(3, c, c));"},
+		{"LocalNames", "function f(a) { [a, b] = arr; }", "function f(a) { $$invalidate(2, [a, b] = arr, b); }"},
+		{"NestedAssignment", "[a] = [c = 2];", "$$invalidate(1, [a] = [$$invalidate(3, c = 2)], a);"},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			result, _ := rw.Rewrite([]byte(td.input))
+			if string(result) != td.output {
+				t.Fatalf("Expected result to be %q but got %q", td.output, result)
+			}
+		})
+	}
+}
+
+func TestRewriteStoreAssignments(t *testing.T) {
+	s, err := Parse(bytes.NewReader([]byte("let count = writable(0);\nlet other = 1;\n$count += other;")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.UseRefs([]byte("other"))
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+
+	result, _ := rw.Rewrite([]byte("$count = 5; other = $count;"))
+	expected := []byte("set_store_value(count, $count = 5, $count); $$invalidate(1, other = $count);")
+	if bytes.Compare(expected, result) != 0 {
+		t.Fatalf("Expected result to be %q but got %q", expected, result)
+	}
+
+	s.UseRefs([]byte("$other"))
+	_, info := NewVarNameRewriter(s, nil).Rewrite([]byte("$count + $other"))
+	if names := info.Names(); len(names) != 2 || names[0] != "$count" || names[1] != "$other" {
+		t.Fatalf("Expected the store values to be vars but got %v", names)
+	}
+	if dirty := info.Dirty(); len(dirty) != 1 || dirty[0] != 5 {
+		t.Fatalf("Expected dirty to be %v but got %v", DirtyMask{5}, dirty)
+	}
+}
+
+func TestDirtyMask(t *testing.T) {
+	testData := []struct {
+		name   string
+		mask   DirtyMask
+		output DirtyMask
+	}{
+		{"FirstChunk", NewDirtyMask(0, 2), DirtyMask{5}},
+		{"LastOfChunk", NewDirtyMask(30), DirtyMask{1 << 30}},
+		{"SecondChunk", NewDirtyMask(1, 31, 33), DirtyMask{2, 5}},
+		{"Or", NewDirtyMask(0).Or(NewDirtyMask(32)), DirtyMask{1, 2}},
+		{"AndNot", NewDirtyMask(0, 1, 32).AndNot(NewDirtyMask(1)), DirtyMask{1, 2}},
+	}
+
+	for _, td := range testData {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			if fmt.Sprint(td.mask) != fmt.Sprint(td.output) {
+				t.Fatalf("Expected mask %v but got %v", td.output, td.mask)
+			}
+		})
+	}
+
+	if !NewDirtyMask(31).AndNot(NewDirtyMask(31)).IsZero() {
+		t.Fatalf("Expected mask with cleared bits to be zero")
+	}
+	if chunks := DirtyChunks(40); chunks != 2 {
+		t.Fatalf("Expected %d chunks for 40 vars but got %d", 2, chunks)
+	}
+}
+
+func TestReactiveAssignmentValue(t *testing.T) {
+	s := parseTestScript(t, "let items = [];\n$: doubled = items.map(double).filter(Boolean);", "")
+
+	for _, r := range s.roots {
+		ln, ok := r.(*LabelNode)
+		if !ok {
+			continue
+		}
+
+		expected := "items.map(double).filter(Boolean)"
+		if value := string(ln.valueJs()); value != expected {
+			t.Fatalf("Expected value %q but got %q", expected, value)
+		}
+		return
+	}
+	t.Fatalf("Expected the reactive assignment to be parsed")
+}
+
+func TestReactiveDeclarations(t *testing.T) {
+	s := parseTestScript(t, "let a;\n$: ({ a, b } = obj);\n$: b = a;", "")
+
+	data, _ := s.RewriteForInstance(NewAssignmentRewriter(s, nil), func(wrapUpds func(WrapUpdFn) []byte) []byte {
+		return wrapUpds(func(_ *VarsInfo, updData []byte) []byte { return updData })
+	})
+	if strings.Count(string(data), "let a;") != 1 || strings.Count(string(data), "let b;") != 1 {
+		t.Fatalf("Expected a and b to be declared once in %q", data)
+	}
+}
+
+func TestCtxVars(t *testing.T) {
+	s := parseTestScript(t, `import { writable } from 'svelte/store';
+	export let title;
+	export const VERSION = 1;
+	const count = writable(0);
+	const LIMIT = 10;
+	let items = [], unused = 0;
+	let total = 0;
+	function helper(x) { return x * LIMIT; }
+	function add() { items = [...items, helper(items.length)]; unused++; }
+	$: total = items.length;
+	$: console.log(debug);
+	let debug;`, "title + $count + total + add")
+
+	names := []string{}
+	for _, cv := range s.ctxVars() {
+		names = append(names, cv.name)
+	}
+	expected := []string{"title", "VERSION", "$count", "items", "total", "add", "debug"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected ctx vars %v but got %v", expected, names)
+	}
+
+	rw := NewAssignmentRewriter(s, func(i int, _ string, _ Var, data []byte) []byte {
+		return []byte(fmt.Sprintf("$$invalidate(%d, %s)", i, data))
+	})
+	result, _ := rw.Rewrite([]byte("items = []; unused++;"))
+	if output := "$$invalidate(3, items = []); unused++;"; string(result) != output {
+		t.Fatalf("Expected result to be %q but got %q", output, result)
+	}
+}
+
+func TestHoist(t *testing.T) {
+	s := parseTestScript(t, `import { format } from './format';
+	const LIMIT = 10, NAME = 'limit';
+	const items = [];
+	const FLAG = true;
+	let count = 0;
+	function clamp(n) { return Math.min(n, LIMIT); }
+	function show(n) { return format(NAME, clamp(n)); }
+	function inc() { count = clamp(count + 1); }
+	function total() { return items.length; }
+	function reserved() { return 1; }
+	function moved() { return 2; }
+	moved = () => 3;
+	FLAG;`, "show(count) + inc + total + LIMIT + reserved + moved")
+	s.Hoist(func(name string) bool { return name == "reserved" })
+
+	expected := "\n\tconst LIMIT = 10, NAME = 'limit';\n\tconst FLAG = true;" +
+		"\n\tfunction clamp(n) { return Math.min(n, LIMIT); }" +
+		"\n\tfunction show(n) { return format(NAME, clamp(n)); }"
+	if hoisted := s.Hoisted(); hoisted != expected {
+		t.Fatalf("Expected hoisted js %q but got %q", expected, hoisted)
+	}
+
+	names := []string{}
+	for _, cv := range s.ctxVars() {
+		names = append(names, cv.name)
+	}
+	if ctx := []string{"count", "inc", "total", "reserved", "moved"}; strings.Join(names, ",") != strings.Join(ctx, ",") {
+		t.Fatalf("Expected ctx vars %v but got %v", ctx, names)
+	}
+
+	rw := NewVarNameRewriter(s, func(i int, name string, _ Var, _ []byte) []byte {
+		return []byte(fmt.Sprintf("ctx[%d]", i))
+	})
+	if result, _ := rw.Rewrite([]byte("show(count) + LIMIT")); string(result) != "show(ctx[0]) + LIMIT" {
+		t.Fatalf("Expected hoisted names to be used directly but got %q", result)
+	}
+}
+
+func TestHoistClasses(t *testing.T) {
+	s := parseTestScript(t, `class K { constructor() { this.n = 1; } }
+	function make() { return new K(); }
+	function size() { return 2; }`, "make() + size() + K")
+	s.Hoist(func(string) bool { return false })
+
+	if hoisted, expected := s.Hoisted(), "\n\tfunction size() { return 2; }"; hoisted != expected {
+		t.Fatalf("Expected hoisted js %q but got %q", expected, hoisted)
+	}
+
+	names := []string{}
+	for _, cv := range s.ctxVars() {
+		names = append(names, cv.name)
+	}
+	if ctx := []string{"K", "make"}; strings.Join(names, ",") != strings.Join(ctx, ",") {
+		t.Fatalf("Expected ctx vars %v but got %v", ctx, names)
+	}
+
+	rw := NewVarNameRewriter(s, func(i int, name string, _ Var, _ []byte) []byte {
+		return []byte(fmt.Sprintf("ctx[%d]", i))
+	})
+	if result, _ := rw.Rewrite([]byte("new K()")); string(result) != "new ctx[0]()" {
+		t.Fatalf("Expected the class to be read from ctx but got %q", result)
+	}
+}
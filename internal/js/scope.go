@@ -0,0 +1,344 @@
+package js
+
+// A scope has the names declared in a program, function or block.
+type scope struct {
+	parent *scope
+	isFunc bool
+	names  map[string]bool
+}
+
+func newScope(parent *scope, isFunc bool) *scope {
+	return &scope{parent, isFunc, map[string]bool{}}
+}
+
+func (s *scope) declare(name string) {
+	s.names[name] = true
+}
+
+// funcScope gets the scope var declarations are hoisted to.
+func (s *scope) funcScope() *scope {
+	for !s.isFunc && s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// lookup finds the scope that declares the name, it's nil when the name isn't
+// declared.
+func (s *scope) lookup(name string) *scope {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return s
+		}
+	}
+	return nil
+}
+
+// A ref is an identifier that references a variable.
+type ref struct {
+	ident     *identExpr
+	scope     *scope
+	shorthand bool
+}
+
+// A scopeAnalysis has the refs of a program, which are resolved after the
+// whole program is walked, since declarations are hoisted.
+type scopeAnalysis struct {
+	root       *scope
+	refs       []*ref
+	assigns    []*assignRef
+	updates    []*updateRef
+	calls      []*callRef
+	shorthands map[*identExpr]bool
+}
+
+// An assignRef is an assignment, with the scope its target is resolved in.
+type assignRef struct {
+	expr  *assignExpr
+	scope *scope
+}
+
+// An updateRef is an increment or decrement, with the scope its argument is
+// resolved in.
+type updateRef struct {
+	expr  *updateExpr
+	scope *scope
+}
+
+// A callRef is a call, with the scope its callee is resolved in.
+type callRef struct {
+	expr  *callExpr
+	scope *scope
+}
+
+func analyzeScopes(prog *program) *scopeAnalysis {
+	a := &scopeAnalysis{
+		root:       newScope(nil, true),
+		shorthands: map[*identExpr]bool{},
+	}
+	a.stmts(a.root, prog.body)
+	return a
+}
+
+// resolvesToRoot reports if the name resolves to the root scope of the
+// program, or to no declaration at all, which both are the vars of the
+// component.
+func (a *scopeAnalysis) resolvesToRoot(s *scope, name string) bool {
+	declScope := s.lookup(name)
+	return declScope == nil || declScope == a.root
+}
+
+// rootRefs returns the refs that resolve to the root scope, in source order.
+func (a *scopeAnalysis) rootRefs() []*ref {
+	refs := []*ref{}
+	for _, r := range a.refs {
+		if a.resolvesToRoot(r.scope, r.ident.name) {
+			refs = append(refs, r)
+		}
+	}
+	return refs
+}
+
+func (a *scopeAnalysis) stmts(s *scope, body []astNode) {
+	for _, n := range body {
+		a.node(s, n)
+	}
+}
+
+func (a *scopeAnalysis) nodes(s *scope, nodes []astNode) {
+	for _, n := range nodes {
+		a.node(s, n)
+	}
+}
+
+// pattern walks a binding pattern, whose identifiers are declared and not
+// referenced, but whose defaults and computed keys are.
+func (a *scopeAnalysis) pattern(s *scope, n astNode) {
+	switch n := n.(type) {
+	case nil, *identExpr:
+	case *parenExpr:
+		a.pattern(s, n.expr)
+	case *assignExpr:
+		a.pattern(s, n.target)
+		a.node(s, n.value)
+	case *spreadExpr:
+		a.pattern(s, n.arg)
+	case *arrayExpr:
+		for _, e := range n.elems {
+			a.pattern(s, e)
+		}
+	case *objectExpr:
+		for _, p := range n.props {
+			if p.computed {
+				a.node(s, p.key)
+			}
+			a.pattern(s, p.value)
+		}
+	default:
+		a.node(s, n)
+	}
+}
+
+// assignTarget walks the target of an assignment, which references the vars
+// it assigns, but where the assignments are the defaults of a pattern.
+func (a *scopeAnalysis) assignTarget(s *scope, n astNode) {
+	switch n := n.(type) {
+	case *parenExpr:
+		a.assignTarget(s, n.expr)
+	case *assignExpr:
+		a.assignTarget(s, n.target)
+		a.node(s, n.value)
+	case *spreadExpr:
+		a.assignTarget(s, n.arg)
+	case *arrayExpr:
+		for _, e := range n.elems {
+			a.assignTarget(s, e)
+		}
+	case *objectExpr:
+		for _, p := range n.props {
+			if p.computed {
+				a.node(s, p.key)
+			}
+			a.markShorthand(p)
+			a.assignTarget(s, p.value)
+		}
+	default:
+		a.node(s, n)
+	}
+}
+
+// markShorthand marks the identifier of a shorthand property, which is both
+// the key and the value of the property.
+func (a *scopeAnalysis) markShorthand(p *property) {
+	if !p.shorthand {
+		return
+	}
+	if names := bindingNames(p.value); len(names) != 0 {
+		a.shorthands[names[0]] = true
+	}
+}
+
+func (a *scopeAnalysis) declarePattern(s *scope, n astNode) {
+	for _, ident := range bindingNames(n) {
+		s.declare(ident.name)
+	}
+	a.pattern(s, n)
+}
+
+func (a *scopeAnalysis) node(s *scope, n astNode) {
+	switch n := n.(type) {
+	case nil, *literalExpr, *emptyStmt:
+	case *identExpr:
+		a.refs = append(a.refs, &ref{n, s, a.shorthands[n]})
+	case *templateExpr:
+		a.node(s, n.tag)
+		a.nodes(s, n.exprs)
+	case *arrayExpr:
+		a.nodes(s, n.elems)
+	case *spreadExpr:
+		a.node(s, n.arg)
+	case *objectExpr:
+		for _, p := range n.props {
+			if p.computed {
+				a.node(s, p.key)
+			}
+			a.markShorthand(p)
+			a.node(s, p.value)
+		}
+	case *funcExpr:
+		if n.decl && n.name != nil {
+			s.declare(n.name.name)
+		}
+		fs := newScope(s, true)
+		if !n.decl && n.name != nil {
+			fs.declare(n.name.name)
+		}
+		for _, param := range n.params {
+			a.declarePattern(fs, param)
+		}
+		if body, ok := n.body.(*blockStmt); ok {
+			a.stmts(fs, body.body)
+		} else {
+			a.node(fs, n.body)
+		}
+	case *classExpr:
+		if n.decl && n.name != nil {
+			s.declare(n.name.name)
+		}
+		a.node(s, n.super)
+		cs := newScope(s, false)
+		if !n.decl && n.name != nil {
+			cs.declare(n.name.name)
+		}
+		for _, m := range n.members {
+			if m.computed {
+				a.node(cs, m.key)
+			}
+			a.node(cs, m.value)
+		}
+	case *unaryExpr:
+		a.node(s, n.arg)
+	case *updateExpr:
+		a.updates = append(a.updates, &updateRef{n, s})
+		a.node(s, n.arg)
+	case *binaryExpr:
+		a.node(s, n.left)
+		a.node(s, n.right)
+	case *assignExpr:
+		a.assigns = append(a.assigns, &assignRef{n, s})
+		a.assignTarget(s, n.target)
+		a.node(s, n.value)
+	case *condExpr:
+		a.node(s, n.test)
+		a.node(s, n.cons)
+		a.node(s, n.alt)
+	case *callExpr:
+		a.calls = append(a.calls, &callRef{n, s})
+		a.node(s, n.callee)
+		a.nodes(s, n.args)
+	case *memberExpr:
+		a.node(s, n.object)
+		if n.computed {
+			a.node(s, n.property)
+		}
+	case *seqExpr:
+		a.nodes(s, n.exprs)
+	case *parenExpr:
+		a.node(s, n.expr)
+	case *varDecl:
+		ds := s
+		if n.kind == "var" {
+			ds = s.funcScope()
+		}
+		for _, d := range n.decls {
+			for _, ident := range bindingNames(d.target) {
+				ds.declare(ident.name)
+			}
+			a.pattern(s, d.target)
+			a.node(s, d.init)
+		}
+	case *exprStmt:
+		a.node(s, n.expr)
+	case *blockStmt:
+		a.stmts(newScope(s, false), n.body)
+	case *ifStmt:
+		a.node(s, n.test)
+		a.node(s, n.cons)
+		a.node(s, n.alt)
+	case *forStmt:
+		fs := newScope(s, false)
+		a.node(fs, n.init)
+		a.node(fs, n.test)
+		a.node(fs, n.update)
+		a.node(fs, n.body)
+	case *forInStmt:
+		fs := newScope(s, false)
+		if _, ok := n.left.(*varDecl); ok {
+			a.node(fs, n.left)
+		} else {
+			a.assignTarget(fs, n.left)
+		}
+		a.node(fs, n.right)
+		a.node(fs, n.body)
+	case *whileStmt:
+		a.node(s, n.test)
+		a.node(s, n.body)
+	case *jumpStmt:
+		a.node(s, n.arg)
+	case *labeledStmt:
+		a.node(s, n.body)
+	case *switchStmt:
+		a.node(s, n.disc)
+		cs := newScope(s, false)
+		for _, c := range n.cases {
+			a.node(cs, c.test)
+			a.stmts(cs, c.body)
+		}
+	case *tryStmt:
+		a.node(s, n.block)
+		if n.handler != nil {
+			hs := newScope(s, false)
+			a.declarePattern(hs, n.param)
+			a.stmts(hs, n.handler.body)
+		}
+		if n.finalizer != nil {
+			a.node(s, n.finalizer)
+		}
+	case *withStmt:
+		a.node(s, n.object)
+		a.node(s, n.body)
+	case *importDecl:
+		for _, spec := range n.specs {
+			s.declare(spec.local.name)
+		}
+	case *exportDecl:
+		a.node(s, n.decl)
+		if n.source == "" {
+			for _, spec := range n.specs {
+				a.node(s, spec.local)
+			}
+		}
+	case *arrowParams:
+		a.nodes(s, n.params)
+	}
+}
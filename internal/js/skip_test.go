@@ -4,130 +4,62 @@ import (
 	"testing"
 )
 
-func TestSkipGroup(t *testing.T) {
+func TestIndexAfterCurlyGroup(t *testing.T) {
 	testData := []struct {
 		name  string
-		input []byte
+		group string
 	}{
-		{"VariableName", []byte("{varName}")},
-		{"VariableWithComment", []byte("{varName/* Some comment with }*/}")},
-		{"BooleanExpression", []byte("{varName === 100}")},
-		{"ArrayLookup", []byte("{varName[100]}")},
-		{"ObjectValue", []byte("{varName.value}")},
-		{"FuctionCall", []byte("{func(100)}")},
-		{"MethodCall", []byte("{varName.func(100)}")},
-		{"RegexString", []byte(`{/ab+c/}`)},
-		{"FuctionCallWithString", []byte("{func('test string')}")},
-		{"FuctionCallWithComplexString", []byte("{func('test string with } and `')}")},
-		{"FuctionCallWithTemplateString", []byte("{func(`test string with ${innerValue} and '`)}")},
-		{"FuctionCallWithStringWithEscapedQuote", []byte(`{func('test string with \' and \\\'')}`)},
-		{"FuctionCallWithStringWithBackslash", []byte(`{func('test string with \\')}`)},
-		{"FuctionCallWithObject", []byte("{func({ value: '100' })}")},
-		{"FuctionCallWithCallback", []byte("{func(() => { return 'value'; })}")},
-		{"FuctionCallWithCallbackReturningObject", []byte("{func(() => ({ some: 'value' }))}")},
+		{"VariableName", "{varName}"},
+		{"VariableWithComment", "{varName/* Some comment with }*/}"},
+		{"BooleanExpression", "{varName === 100}"},
+		{"ArrayLookup", "{varName[100]}"},
+		{"ObjectValue", "{varName.value}"},
+		{"FuctionCall", "{func(100)}"},
+		{"MethodCall", "{varName.func(100)}"},
+		{"RegexString", `{/ab+c}/.test(value)}`},
+		{"RegexWithClass", `{value.replace(/[}/]/g, '')}`},
+		{"Division", "{total / count}"},
+		{"DivisionAfterCall", "{sum(a) / 2}"},
+		{"DivisionAssignment", "{value /= 2}"},
+		{"FuctionCallWithString", "{func('test string')}"},
+		{"FuctionCallWithComplexString", "{func('test string with } and `')}"},
+		{"FuctionCallWithTemplateString", "{func(`test string with ${innerValue} and '`)}"},
+		{"NestedTemplateStrings", "{`a ${`b ${`c ${value}}`}}`} }`}"},
+		{"TemplateStringWithObject", "{`${JSON.stringify({ a: '}' })}`}"},
+		{"FuctionCallWithStringWithEscapedQuote", `{func('test string with \' and \\\'')}`},
+		{"FuctionCallWithStringWithBackslash", `{func('test string with \\')}`},
+		{"FuctionCallWithObject", "{func({ value: '100' })}"},
+		{"FuctionCallWithCallback", "{func(() => { return 'value'; })}"},
+		{"FuctionCallWithCallbackReturningObject", "{func(() => ({ some: 'value' }))}"},
 		{
 			"FuctionCallWithComplexCallback",
-			[]byte(`{func(() => {
-				// Some comment with 
+			`{func(() => {
+				// Some comment with }
 				const value = "test string with ) and }";
 
 				return '"' + value + '"';
-			})}`),
+			})}`,
 		},
 	}
-
-	for _, td := range testData {
-		t.Run(td.name, func(t *testing.T) {
-			skpr := newCurlyGroupSkipper()
-			i := 1
-			for skpr.isOpen() {
-				if i >= len(td.input) {
-					t.Fatalf("Group still open (with %d left) after end of input", skpr.count)
-				}
-
-				skpr.next(td.input[i])
-				i += 1
-			}
-
-			if i != len(td.input) {
-				t.Fatalf("Closed group at %d instead of %d", i, len(td.input)-1)
-			}
-		})
-	}
-}
-
-func TestSkipQuote(t *testing.T) {
-	testData := []struct {
-		name  string
-		input []byte
-	}{
-		{"SingleQuote", []byte("'some text'")},
-		{"DoubleQuote", []byte(`"some text"`)},
-		{"TmplQuote", []byte("`some text`")},
-		{"RegexQuote", []byte(`/ab+c/`)},
-		{"QuoteWithEscape", []byte(`'some text with \''`)},
-		{"QuoteWithMultiEscape", []byte(`'some text with \\'`)},
-		{"TmplWithExpr", []byte("`some ${more} text`")},
-		{"TmplWithTmplInExpr", []byte("`some ${func(() => `another ${tmpl}`)} text`")},
+	sufixes := []string{
+		"",
+		" / some text after",
+		" and {anotherExpr} plus more text",
 	}
 
 	for _, td := range testData {
+		td := td
 		t.Run(td.name, func(t *testing.T) {
-			skpr := newQuoteSkipper(td.input[0])
-			i := 1
-			for skpr.isOpen() {
-				if i >= len(td.input) {
-					t.Fatal("Quote still open after end of input")
+			for _, sufix := range sufixes {
+				input := td.group + sufix
+				if index := IndexAfterCurlyGroup([]byte(input)); index != len(td.group) {
+					t.Fatalf("Expected index %d but got %d for %q", len(td.group), index, input)
 				}
-
-				skpr.next(td.input[i])
-				i += 1
-			}
-
-			if i != len(td.input) {
-				t.Fatalf("Closed quote at %d instead of %d", i, len(td.input))
 			}
 		})
 	}
-}
-
-func TestSkipComment(t *testing.T) {
-	testData := []struct {
-		name  string
-		open  string
-		close string
-		input []byte
-	}{
-		{"OneLineComment", lineCommentOpen, newLine, []byte("//Some Comment\n")},
-		{"BlockComment", blockCommentOpen, blockCommentClose, []byte("/* Some Comment */")},
-		{
-			"MultiLineBlockComment",
-			blockCommentOpen,
-			blockCommentClose,
-			[]byte(
-				`/*
-	Some Comment
-*/`,
-			),
-		},
-	}
 
-	for _, td := range testData {
-		t.Run(td.name, func(t *testing.T) {
-			skpr := newCommentSkipper([]byte(td.open), []byte(td.close))
-			i := 1
-			for skpr.isOpen() {
-				if i >= len(td.input) {
-					t.Fatal("Comment still open after end of input")
-				}
-
-				skpr.next(td.input[i])
-				i += 1
-			}
-
-			if i != len(td.input) {
-				t.Fatalf("Closed comment at %d instead of %d", i, len(td.input))
-			}
-		})
+	if index := IndexAfterCurlyGroup([]byte("{func(")); index != -1 {
+		t.Fatalf("Expected index -1 for an unclosed group but got %d", index)
 	}
 }
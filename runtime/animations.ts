@@ -2,7 +2,7 @@ import { identity as linear, noop } from './utils';
 import { now } from './environment';
 import { loop } from './loop';
 import { create_rule, delete_rule } from './style_manager';
-import { AnimationConfig } from '../animate';
+import { AnimationConfig } from './animate';
 
 
 //todo: documentation says it is DOMRect, but in IE it would be ClientRect
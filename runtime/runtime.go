@@ -0,0 +1,10 @@
+// Package runtime embeds the ts source of the runtime, which the generated js
+// of components imports its helpers from.
+package runtime
+
+import "embed"
+
+// FS has the ts files of the runtime, index.ts exports the helpers.
+//
+//go:embed *.ts
+var FS embed.FS
@@ -0,0 +1,14 @@
+export {
+	onMount,
+	onDestroy,
+	beforeUpdate,
+	afterUpdate,
+	setContext,
+	getContext,
+	getAllContexts,
+	hasContext,
+	createEventDispatcher
+} from './lifecycle';
+export { tick } from './scheduler';
+export { SvelteComponent } from './Component';
+export { SvelteComponentTyped } from './dev';
@@ -4,7 +4,7 @@ import { loop } from './loop';
 import { create_rule, delete_rule } from './style_manager';
 import { custom_event } from './dom';
 import { add_render_callback } from './scheduler';
-import { TransitionConfig } from '../transition';
+import { TransitionConfig } from './transition';
 import { Fragment } from './Component';
 
 let promise: Promise<void> | null;
@@ -1,4 +1,4 @@
-//import { Readable } from 'svelte/store';
+import { Readable } from './store';
 
 export function noop() {}
 
@@ -72,11 +72,11 @@ export function subscribe(store, ...callbacks) {
 	return unsub.unsubscribe ? () => unsub.unsubscribe() : unsub;
 }
 
-// export function get_store_value<T>(store: Readable<T>): T {
-// 	let value;
-// 	subscribe(store, _ => value = _)();
-// 	return value;
-// }
+export function get_store_value<T>(store: Readable<T>): T {
+	let value;
+	subscribe(store, _ => value = _)();
+	return value;
+}
 
 export function component_subscribe(component, store, callback) {
 	component.$$.on_destroy.push(subscribe(store, callback));
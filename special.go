@@ -0,0 +1,708 @@
+package sveltish
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/progrium/sveltish/internal/html"
+	"github.com/progrium/sveltish/internal/js"
+)
+
+// A windowBinding describes how a bind: directive on <svelte:window /> reads
+// its value.
+type windowBinding struct {
+	handler string
+	events  []string
+	value   string
+}
+
+var windowBindings = map[string]windowBinding{
+	"innerWidth":       {"onwindowresize", []string{"resize"}, "window.innerWidth"},
+	"innerHeight":      {"onwindowresize", []string{"resize"}, "window.innerHeight"},
+	"outerWidth":       {"onwindowresize", []string{"resize"}, "window.outerWidth"},
+	"outerHeight":      {"onwindowresize", []string{"resize"}, "window.outerHeight"},
+	"devicePixelRatio": {"onwindowresize", []string{"resize"}, "window.devicePixelRatio"},
+	"scrollX":          {"onwindowscroll", []string{"scroll"}, "window.pageXOffset"},
+	"scrollY":          {"onwindowscroll", []string{"scroll"}, "window.pageYOffset"},
+	"online":           {"onlinestatuschanged", []string{"online", "offline"}, "navigator.onLine"},
+}
+
+// addTarget will generate the listeners and bindings of a special element
+// that targets a global object.
+func (sg *scriptGenerator) addTarget(b *blockGenerator, tn *TargetNode) error {
+	handlers := []string{}
+	handlerStmts := map[string][]string{}
+	handlerEvents := map[string][]string{}
+	scrollCtx := map[string]string{}
+	scrollNames := []string{}
+	scrollDirty := js.DirtyMask{}
+
+	for _, attr := range tn.node.Attrs() {
+		dir, exists := attr.Dir()
+		if !exists {
+			return errors.New("Invaild attribute on <" + tn.node.Tag() + " />, " + attr.Name())
+		}
+
+		attContent, info := attr.RewriteJs(sg.nrw)
+		switch attr.Name() {
+		case "on":
+			b.insertf(
+				lsn,
+				"listen(%s, '%s', %s)",
+				tn.target,
+				dir,
+				sg.eventHandler(dir, attr),
+			)
+		case "bind":
+			wb, exists := windowBindings[dir]
+			if !exists || tn.target != specialTargets["svelte:window"] {
+				return errors.New("Invaild binding on <" + tn.node.Tag() + " />, bind:" + dir)
+			}
+
+			varName, _ := attr.RewriteJs(js.NewVarNameRewriter(nil, nil))
+			if names := info.Names(); len(names) != 1 || names[0] != strings.TrimSpace(string(varName)) {
+				return errors.New("Can only bind to a root variable, bind:" + dir)
+			}
+
+			if _, exists := handlerStmts[wb.handler]; !exists {
+				handlers = append(handlers, wb.handler)
+				handlerEvents[wb.handler] = wb.events
+			}
+			stmt, _ := sg.arw.Rewrite([]byte(fmt.Sprintf("%s = %s", varName, wb.value)))
+			handlerStmts[wb.handler] = append(handlerStmts[wb.handler], string(stmt)+";")
+
+			if wb.handler == windowBindings["scrollY"].handler {
+				scrollCtx[dir] = string(attContent)
+				scrollNames = append(scrollNames, info.Names()...)
+				scrollDirty = scrollDirty.Or(info.Dirty())
+			}
+		default:
+			return errors.New("Invaild attribute with directive on <" + tn.node.Tag() + " />, " + attr.Name() + ":" + dir)
+		}
+	}
+
+	for _, handler := range handlers {
+		name := sg.instanceName(handler)
+		handlerCtx := fmt.Sprintf("/* %s */ ctx[%d]", name, sg.ctxIndex(name))
+		sg.instBody += fmt.Sprintf(
+			"\nfunction %s() {\n\t%s\n}\n",
+			name,
+			strings.Join(handlerStmts[handler], "\n\t"),
+		)
+
+		if handler != windowBindings["scrollY"].handler {
+			b.insertf(ini, "add_render_callback(%s)", handlerCtx)
+			for _, event := range handlerEvents[handler] {
+				b.insertf(lsn, "listen(%s, '%s', %s)", tn.target, event, handlerCtx)
+			}
+			continue
+		}
+
+		b.insert(ini, "let scrolling = false")
+		b.insert(ini, "let clear_scrolling = () => { scrolling = false; }")
+		b.insert(ini, "let scrolling_timeout")
+		b.insertf(ini, "add_render_callback(%s)", handlerCtx)
+		b.insertf(
+			lsn,
+			"listen(%s, 'scroll', () => { scrolling = true; clearTimeout(scrolling_timeout); scrolling_timeout = setTimeout(clear_scrolling, 100); %s(); })",
+			tn.target,
+			handlerCtx,
+		)
+
+		x, hasX := scrollCtx["scrollX"]
+		if !hasX {
+			x = "window.pageXOffset"
+		}
+		y, hasY := scrollCtx["scrollY"]
+		if !hasY {
+			y = "window.pageYOffset"
+		}
+		b.insertf(
+			upd,
+			"if (%s && !scrolling) { scrolling = true; clearTimeout(scrolling_timeout); scrollTo(%s, %s); scrolling_timeout = setTimeout(clear_scrolling, 100); }",
+			sg.dirtyCheck("dirty", scrollNames, scrollDirty),
+			x,
+			y,
+		)
+	}
+
+	return nil
+}
+
+// instanceName gets the name of a function the generated js adds to the
+// instance, that doesn't clash with the names the script declares or the
+// other functions the generated js adds.
+func (sg *scriptGenerator) instanceName(name string) string {
+	newName := name
+	for i := 1; containsString(sg.scriptNames, newName) || containsString(sg.instReturns, newName); i++ {
+		newName = fmt.Sprintf("%s_%d", name, i)
+	}
+	return newName
+}
+
+// eventHandler gets the js of the handler of an on: directive. Inline
+// handlers that assign vars are moved to the instance like svelte does, so the
+// assignments invalidate the vars, and they get the let: vars of the slot they
+// are in as args.
+func (sg *scriptGenerator) eventHandler(event string, attr html.Attr) []byte {
+	attContent, info := attr.RewriteJs(sg.nrw)
+	handler, assignInfo := attr.RewriteJs(sg.arw)
+	if len(assignInfo.Names()) == 0 {
+		return attContent
+	}
+
+	name := sg.instanceName(strings.NewReplacer("-", "_", ":", "_").Replace(event) + "_handler")
+	handler = bytes.TrimSpace(handler)
+	lets := []string{}
+	args := []string{}
+	for _, varName := range info.Names() {
+		if !containsString(sg.lets, varName) {
+			continue
+		}
+		i, _ := info.Index(varName)
+		lets = append(lets, varName)
+		args = append(args, fmt.Sprintf("/* %s */ ctx[%d]", varName, i))
+	}
+
+	if len(lets) == 0 {
+		sg.instBody += fmt.Sprintf("\nconst %s = %s;\n", name, handler)
+		return []byte(fmt.Sprintf("/* %s */ ctx[%d]", name, sg.ctxIndex(name)))
+	}
+
+	sg.instBody += fmt.Sprintf("\nconst %s = (%s) => %s;\n", name, strings.Join(lets, ", "), handler)
+	return []byte(fmt.Sprintf(
+		"function (...args) { return /* %s */ ctx[%d](%s).apply(this, args); }",
+		name,
+		sg.ctxIndex(name),
+		strings.Join(args, ", "),
+	))
+}
+
+// thisBinding adds the function to the instance that sets the variable bound
+// with bind:this, and returns the ctx expression the node is passed to.
+func (sg *scriptGenerator) thisBinding(name string, attr html.Attr) (string, error) {
+	_, info := attr.RewriteJs(sg.nrw)
+	varName, _ := attr.RewriteJs(js.NewVarNameRewriter(nil, nil))
+	if names := info.Names(); len(names) != 1 || names[0] != strings.TrimSpace(string(varName)) {
+		return "", errors.New("Can only bind to a root variable, bind:this")
+	}
+
+	handler := sg.instanceName(name + "_binding")
+	stmt, _ := sg.arw.Rewrite([]byte(fmt.Sprintf("%s = $$value", varName)))
+	sg.instBody += fmt.Sprintf(
+		"\nfunction %s($$value) {\n\tbinding_callbacks[$$value ? 'unshift' : 'push'](() => {\n\t\t%s;\n\t});\n}\n",
+		handler,
+		stmt,
+	)
+	return fmt.Sprintf("/* %s */ ctx[%d]", handler, sg.ctxIndex(handler)), nil
+}
+
+// addTitle will generate the setting and updating of the document title from
+// the <title /> element in <svelte:head />.
+func (sg *scriptGenerator) addTitle(b *blockGenerator, title *html.ElNode) {
+	tmpl := []string{}
+	allInfo := []*js.VarsInfo{}
+	for _, child := range title.Children() {
+		switch node := child.(type) {
+		case *html.TxtNode:
+			tmpl = append(tmpl, strings.NewReplacer("\\", "\\\\", "`", "\\`", "${", "\\${").Replace(node.Content()))
+		case *html.ExprNode:
+			data, info := node.RewriteJs(sg.nrw)
+			tmpl = append(tmpl, "${"+string(data)+"}")
+			allInfo = append(allInfo, info)
+		}
+	}
+	info := js.MergeVarsInfo(allInfo...)
+	value := "`" + strings.Join(tmpl, "") + "`"
+
+	b.insert(dec, "let title_value")
+	b.insertf(ini, "document.title = title_value = %s", value)
+
+	if titleDirty := info.Dirty(); !titleDirty.IsZero() {
+		b.track(info.Names(), titleDirty)
+		b.insertf(
+			upd,
+			"if (%s && title_value !== (title_value = %s)) document.title = title_value",
+			sg.dirtyCheck("dirty", info.Names(), titleDirty),
+			value,
+		)
+	}
+}
+
+// componentOptions creates the options a child component is constructed with,
+// along with the listeners added to it and the statements that collect the
+// changes to it's props.
+func (sg *scriptGenerator) componentOptions(b *blockGenerator, nv *NodeVar, en *html.ElNode) (string, []string, []string, error) {
+	props := []string{}
+	listeners := []string{}
+	changes := []string{}
+	for _, attr := range en.Attrs() {
+		if (nv.slot != "" && attr.Name() == "slot") || (en.Tag() == componentTag && attr.Name() == "this") {
+			continue
+		}
+
+		attContent, info := attr.RewriteJs(sg.nrw)
+
+		dir, exists := attr.Dir()
+		if exists {
+			if attr.Name() == "let" {
+				continue
+			}
+			if attr.Name() == "bind" && dir == "this" {
+				binding, err := sg.thisBinding(nv.name, attr)
+				if err != nil {
+					return "", nil, nil, err
+				}
+				listeners = append(listeners, fmt.Sprintf("%s(%s)", binding, nv.name))
+				b.insertf(det, "%s(null)", binding)
+				continue
+			}
+			if name := attr.Name(); name != "on" {
+				return "", nil, nil, errors.New("Invaild attribute with directive on <" + en.Tag() + " />, " + name + ":" + dir)
+			}
+
+			listeners = append(listeners, fmt.Sprintf("%s.$on('%s', %s)", nv.name, dir, sg.eventHandler(dir, attr)))
+			continue
+		}
+
+		props = append(props, fmt.Sprintf("%s: %s", propKey(attr.Name()), attContent))
+		if attrDirty := info.Dirty(); !attrDirty.IsZero() {
+			b.track(info.Names(), attrDirty)
+			changes = append(changes, fmt.Sprintf(
+				"if (%s) %s_changes%s = %s",
+				sg.dirtyCheck("dirty", info.Names(), attrDirty),
+				nv.name,
+				propAccessor(attr.Name()),
+				attContent,
+			))
+		}
+	}
+
+	if len(nv.slots) != 0 {
+		slotDefs := []string{}
+		scopeNames := []string{}
+		scopeDirty := js.DirtyMask{}
+		for _, slot := range nv.slots {
+			sb := newBlockGenerator(
+				fmt.Sprintf("create_%s_%s_slot", nv.name, strings.NewReplacer("-", "_", ":", "_").Replace(slot.name)),
+				"dirty",
+			)
+
+			letNames := []string{}
+			letDirty := js.DirtyMask{}
+			slotDef := sb.name
+			if len(slot.lets) != 0 {
+				scoped := map[string]int{}
+				letProps := []string{}
+				letCtx := []string{}
+				letMasks := []js.DirtyMask{}
+				for _, l := range slot.lets {
+					i := sg.letIndex(l.name)
+					scoped[l.name] = i
+					letNames = append(letNames, l.name)
+					letDirty = letDirty.Or(js.NewDirtyMask(i))
+
+					if l.prop == l.name {
+						letProps = append(letProps, l.name)
+					} else {
+						letProps = append(letProps, fmt.Sprintf("%s: %s", propKey(l.prop), l.name))
+					}
+					letCtx = append(letCtx, fmt.Sprintf("%d: %s", i, l.name))
+					letMasks = append(letMasks, js.NewDirtyMask(i))
+				}
+				slotDef = fmt.Sprintf(
+					"%s, ({ %s }) => ({ %s }), ({ %s }) => %s",
+					sb.name,
+					strings.Join(letProps, ", "),
+					strings.Join(letCtx, ", "),
+					strings.Join(letProps, ", "),
+					sg.dirtyValue(letNames, letMasks),
+				)
+
+				nrw := sg.nrw
+				sg.nrw = js.WithScopedVars(nrw, scoped)
+				err := sg.addNodes(sb, slot.nodes)
+				sg.nrw = nrw
+				if err != nil {
+					return "", nil, nil, err
+				}
+			} else if err := sg.addNodes(sb, slot.nodes); err != nil {
+				return "", nil, nil, err
+			}
+			sg.blocks = append(sg.blocks, sb)
+
+			slotDefs = append(slotDefs, fmt.Sprintf("%s: [%s]", propKey(slot.name), slotDef))
+			for _, name := range sb.depNames {
+				if !containsString(scopeNames, name) && !containsString(letNames, name) {
+					scopeNames = append(scopeNames, name)
+				}
+			}
+			scopeDirty = scopeDirty.Or(sb.depDirty.AndNot(letDirty))
+		}
+
+		props = append(props, "$$slots: { "+strings.Join(slotDefs, ", ")+" }", "$$scope: { ctx }")
+		if !scopeDirty.IsZero() {
+			b.track(scopeNames, scopeDirty)
+			changes = append(changes, fmt.Sprintf(
+				"if (%s) %s_changes.$$scope = { dirty, ctx }",
+				sg.dirtyCheck("dirty", scopeNames, scopeDirty),
+				nv.name,
+			))
+		}
+	}
+
+	opts := "{}"
+	if len(props) != 0 {
+		opts = "{ props: { " + strings.Join(props, ", ") + " } }"
+	}
+	return opts, listeners, changes, nil
+}
+
+// addComponent will generate the creating, mounting and updating of a child
+// component that is an instance of the named class.
+func (sg *scriptGenerator) addComponent(b *blockGenerator, nv *NodeVar, en *html.ElNode, class string) error {
+	opts, listeners, changes, err := sg.componentOptions(b, nv, en)
+	if err != nil {
+		return err
+	}
+
+	b.insertf(ini, "let %s = new %s(%s)", nv.name, class, opts)
+	for _, listener := range listeners {
+		b.insert(ini, listener)
+	}
+	b.insertf(set, "create_component(%s.$$.fragment)", nv.name)
+
+	target, anchor := mountTarget(nv)
+	b.insertf(mnt, "mount_component(%s, %s, %s)", nv.name, target, anchor)
+	b.insertf(det, "destroy_component(%s, %s)", nv.name, componentDetaching(nv))
+
+	if len(changes) != 0 {
+		b.insertf(upd, "const %s_changes = {}", nv.name)
+		for _, change := range changes {
+			b.insert(upd, change)
+		}
+		b.insertf(upd, "%s.$set(%s_changes)", nv.name, nv.name)
+	}
+	return nil
+}
+
+// addDynamicComponent will generate a child component that is destroyed and
+// created again whenever the class it is an instance of changes.
+func (sg *scriptGenerator) addDynamicComponent(b *blockGenerator, nv *NodeVar, en *html.ElNode) error {
+	thisAttr, _ := thisAttrOf(en)
+	thisContent, thisInfo := thisAttr.RewriteJs(sg.nrw)
+
+	opts, listeners, changes, err := sg.componentOptions(b, nv, en)
+	if err != nil {
+		return err
+	}
+
+	valueName := nv.name + "_value"
+	propsName := nv.name + "_props"
+	anchorName := nv.name + "_anchor"
+	create := fmt.Sprintf("%s = new %s(%s(ctx))", nv.name, valueName, propsName)
+	for _, listener := range listeners {
+		create += "; " + listener
+	}
+
+	b.insertf(dec, "let %s = %s", valueName, thisContent)
+	b.insertf(dec, "let %s", anchorName)
+	b.insertf(dec, "let %s", nv.name)
+	b.insertf(dec, "const %s = ctx => (%s)", propsName, opts)
+	b.insertf(ini, "if (%s) { %s }", valueName, create)
+	b.insertf(set, "if (%s) create_component(%s.$$.fragment)", nv.name, nv.name)
+	b.insertf(set, "%s = empty()", anchorName)
+
+	target, anchor := mountTarget(nv)
+	b.insertf(mnt, "if (%s) mount_component(%s, %s, %s)", nv.name, nv.name, target, anchor)
+	b.mount(nv, anchorName)
+	b.insertf(det, "if (%s) destroy_component(%s, %s)", nv.name, nv.name, componentDetaching(nv))
+
+	if len(changes) != 0 {
+		b.insertf(upd, "const %s_changes = {}", nv.name)
+		for _, change := range changes {
+			b.insert(upd, change)
+		}
+	}
+
+	setChanges := ""
+	if len(changes) != 0 {
+		setChanges = fmt.Sprintf("%s.$set(%s_changes)", nv.name, nv.name)
+	}
+
+	thisDirty := thisInfo.Dirty()
+	if thisDirty.IsZero() {
+		if setChanges != "" {
+			b.insertf(upd, "if (%s) %s", nv.name, setChanges)
+		}
+		return nil
+	}
+
+	b.track(thisInfo.Names(), thisDirty)
+	switchStmt := fmt.Sprintf(
+		"if (%s && %s !== (%s = %s)) { if (%s) destroy_component(%s, 1); if (%s) { %s; create_component(%s.$$.fragment); mount_component(%s, %s.parentNode, %s) } else { %s = null } }",
+		sg.dirtyCheck("dirty", thisInfo.Names(), thisDirty),
+		valueName,
+		valueName,
+		thisContent,
+		nv.name,
+		nv.name,
+		valueName,
+		create,
+		nv.name,
+		nv.name,
+		anchorName,
+		anchorName,
+		nv.name,
+	)
+	if setChanges != "" {
+		switchStmt += fmt.Sprintf(" else if (%s) { %s }", nv.name, setChanges)
+	}
+	b.insert(upd, switchStmt)
+	return nil
+}
+
+// letIndex gets the index in ctx of a var set by a let: directive, the vars
+// that do not shadow an instance variable get a placeholder in the instance
+// returns that the slot context replaces.
+func (sg *scriptGenerator) letIndex(name string) int {
+	for i, returned := range sg.instReturns {
+		if returned == name {
+			return i
+		}
+	}
+
+	sg.lets = append(sg.lets, name)
+	return sg.ctxIndex(name)
+}
+
+// componentDetaching gets the detaching argument a child component is
+// destroyed with.
+func componentDetaching(nv *NodeVar) string {
+	if nv.hasParent {
+		return "0"
+	} else if nv.inHead {
+		return "1"
+	}
+	return "detaching"
+}
+
+// addDynamicElement will generate an element in it's own block, that is
+// destroyed and created again whenever the tag of the element changes.
+func (sg *scriptGenerator) addDynamicElement(b *blockGenerator, nv *NodeVar, en *html.ElNode) error {
+	thisAttr, _ := thisAttrOf(en)
+	tagContent, tagInfo := thisAttr.RewriteJs(sg.nrw)
+
+	eb := newBlockGenerator(fmt.Sprintf("create_%s_block", nv.name), "dirty")
+	root := NewNodeVar(nv.name, nv.node)
+	eb.insertf(dec, "let %s", nv.name)
+	eb.insertf(set, "%s = %s(%s)", nv.name, sg.elementFn(nv), tagContent)
+	eb.mount(root, nv.name)
+
+	attrs := []html.Attr{}
+	for _, attr := range en.Attrs() {
+		if _, hasDir := attr.Dir(); hasDir || attr.Name() != "this" {
+			attrs = append(attrs, attr)
+		}
+	}
+	if err := sg.addAttrs(eb, nv, "", attrs); err != nil {
+		return err
+	}
+	if err := sg.addNodes(eb, nv.children); err != nil {
+		return err
+	}
+	sg.blocks = append(sg.blocks, eb)
+
+	tagName := nv.name + "_tag"
+	anchorName := nv.name + "_anchor"
+	blockName := nv.name + "_block"
+	create := fmt.Sprintf(
+		"%s = %s(ctx); %s.c(); %s.m(%s.parentNode, %s)",
+		blockName,
+		eb.name,
+		blockName,
+		blockName,
+		anchorName,
+		anchorName,
+	)
+
+	b.insertf(dec, "let %s = %s", tagName, tagContent)
+	b.insertf(dec, "let %s", anchorName)
+	b.insertf(ini, "let %s = %s && %s(ctx)", blockName, tagName, eb.name)
+	b.insertf(set, "if (%s) %s.c()", blockName, blockName)
+	b.insertf(set, "%s = empty()", anchorName)
+
+	target, anchor := mountTarget(nv)
+	b.insertf(mnt, "if (%s) %s.m(%s, %s)", blockName, blockName, target, anchor)
+	b.mount(nv, anchorName)
+	b.insertf(det, "if (%s) %s.d(detaching)", blockName, blockName)
+
+	b.track(tagInfo.Names(), tagInfo.Dirty())
+	b.track(eb.depNames, eb.depDirty)
+	b.insertf(upd, "const %s_previous = %s", tagName, tagName)
+	b.insertf(upd, "%s = %s", tagName, tagContent)
+	b.insertf(
+		upd,
+		"if (%s) { if (!%s_previous) { %s } else if (safe_not_equal(%s_previous, %s)) { %s.d(1); %s } else { %s.p(ctx, dirty) } } else if (%s_previous) { %s.d(1); %s = null }",
+		tagName,
+		tagName,
+		create,
+		tagName,
+		tagName,
+		blockName,
+		create,
+		blockName,
+		tagName,
+		blockName,
+		blockName,
+	)
+	return nil
+}
+
+// addSlot will generate the rendering of the content passed into a slot by
+// the parent component, or the fallback content when none is passed.
+func (sg *scriptGenerator) addSlot(b *blockGenerator, nv *NodeVar, en *html.ElNode) error {
+	slotName := defaultSlot
+	for _, attr := range en.Attrs() {
+		if _, hasDir := attr.Dir(); hasDir || attr.Name() != "name" {
+			continue
+		}
+
+		name, isStatic := html.StaticValue(attr)
+		if !isStatic {
+			return errors.New("The name of a <slot /> cannot be dynamic")
+		}
+		slotName = name
+	}
+
+	sg.usesSlots = true
+	slotsCtx := fmt.Sprintf("/* slots */ ctx[%d]", sg.ctxIndex("slots"))
+	scopeIndex := sg.ctxIndex("$$scope")
+	scopeCtx := fmt.Sprintf("/* $$scope */ ctx[%d]", scopeIndex)
+	scopeDirty := js.NewDirtyMask(scopeIndex)
+
+	slotProps := []string{}
+	slotChanges := []string{}
+	propsNames := []string{"$$scope"}
+	propsDirty := scopeDirty
+	for _, attr := range en.Attrs() {
+		if _, hasDir := attr.Dir(); hasDir || attr.Name() == "name" {
+			continue
+		}
+
+		attContent, info := attr.RewriteJs(sg.nrw)
+		slotProps = append(slotProps, fmt.Sprintf("%s: %s", propKey(attr.Name()), attContent))
+		if info.Dirty().IsZero() {
+			continue
+		}
+
+		slotChanges = append(slotChanges, fmt.Sprintf(
+			"%s: %s",
+			propKey(attr.Name()),
+			sg.dirtyCheck("dirty", info.Names(), info.Dirty()),
+		))
+		for _, name := range info.Names() {
+			if !containsString(propsNames, name) {
+				propsNames = append(propsNames, name)
+			}
+		}
+		propsDirty = propsDirty.Or(info.Dirty())
+	}
+
+	getContext := "null"
+	getChanges := "null"
+	if len(slotProps) != 0 {
+		getContext = nv.name + "_get_context"
+		getChanges = nv.name + "_get_changes"
+		b.insertf(dec, "const %s = ctx => ({ %s })", getContext, strings.Join(slotProps, ", "))
+		b.insertf(dec, "const %s = dirty => ({ %s })", getChanges, strings.Join(slotChanges, ", "))
+	}
+
+	tmplName := nv.name + "_template"
+	b.insertf(dec, "const %s = %s%s", tmplName, slotsCtx, propAccessor(slotName))
+	b.insertf(dec, "const %s = create_slot(%s, ctx, %s, %s)", nv.name, tmplName, scopeCtx, getContext)
+	b.track(propsNames, propsDirty)
+
+	slotUpd := fmt.Sprintf(
+		"if (%s) { if (%s.p && %s) update_slot_base(%s, %s, ctx, %s, get_slot_changes(%s, %s, dirty, %s), %s) }",
+		nv.name,
+		nv.name,
+		sg.dirtyCheck("dirty", propsNames, propsDirty),
+		nv.name,
+		tmplName,
+		scopeCtx,
+		tmplName,
+		scopeCtx,
+		getChanges,
+		getContext,
+	)
+
+	slotOrFallback := nv.name
+	hasFallback := false
+	for _, child := range nv.children {
+		if tn, ok := child.node.(*html.TxtNode); !ok || !html.IsContentWhiteSpace(tn) {
+			hasFallback = true
+		}
+	}
+	if hasFallback {
+		fb := newBlockGenerator(fmt.Sprintf("create_%s_fallback", nv.name), "dirty")
+		if err := sg.addNodes(fb, nv.children); err != nil {
+			return err
+		}
+		sg.blocks = append(sg.blocks, fb)
+
+		slotOrFallback = nv.name + "_or_fallback"
+		b.insertf(dec, "const %s = %s || %s(ctx)", slotOrFallback, nv.name, fb.name)
+
+		if !fb.depDirty.IsZero() {
+			b.track(fb.depNames, fb.depDirty)
+			slotUpd += fmt.Sprintf(
+				" else if (%s && %s.p && %s) { %s.p(ctx, dirty) }",
+				slotOrFallback,
+				slotOrFallback,
+				sg.dirtyCheck("dirty", fb.depNames, fb.depDirty),
+				slotOrFallback,
+			)
+		}
+	}
+
+	target, anchor := mountTarget(nv)
+	b.insertf(set, "if (%s) %s.c()", slotOrFallback, slotOrFallback)
+	b.insertf(mnt, "if (%s) %s.m(%s, %s)", slotOrFallback, slotOrFallback, target, anchor)
+	b.insert(upd, slotUpd)
+	b.insertf(det, "if (%s) %s.d(detaching)", slotOrFallback, slotOrFallback)
+	return nil
+}
+
+// propKey gets the key a prop or slot is given in an object literal.
+func propKey(name string) string {
+	if isIdentifier(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", `\'`) + "'"
+}
+
+// propAccessor gets the member access of a prop or slot in an object.
+func propAccessor(name string) string {
+	if isIdentifier(name) {
+		return "." + name
+	}
+	return "[" + propKey(name) + "]"
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, c := range name {
+		if c == '_' || c == '$' || unicode.IsLetter(c) || (i != 0 && unicode.IsDigit(c)) {
+			continue
+		}
+		return false
+	}
+	return true
+}
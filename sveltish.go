@@ -6,17 +6,45 @@ import (
 	"strings"
 )
 
-func Build(path string) ([]byte, error) {
-	f, err := os.Open(path)
+// DefaultRuntime is the module specifier the generated js imports the runtime
+// helpers from, when the compile options don't have one.
+const DefaultRuntime = "sveltish/runtime"
+
+// CompileOptions are the options for compiling components, unlike Options
+// they are set by the build and not by the components.
+type CompileOptions struct {
+	// Runtime is the module specifier the generated js imports the runtime
+	// helpers from, it is DefaultRuntime when empty.
+	Runtime string
+	// RuntimeExports are the names the runtime exports, when they are set
+	// compiling fails if the generated js imports helpers that aren't in them.
+	RuntimeExports []string
+}
+
+func (opts CompileOptions) runtime() string {
+	if opts.Runtime == "" {
+		return DefaultRuntime
+	}
+	return opts.Runtime
+}
+
+func Build(path string, opts CompileOptions) ([]byte, error) {
+	c, err := ParseFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	name := strings.Replace(filepath.Base(path), filepath.Ext(path), "", 1)
-	c, err := Parse(name, f)
+	return GenerateJS(c, opts)
+}
+
+// ParseFile parses the component in the file, which is named after the file.
+func ParseFile(path string) (*Component, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	return GenerateJS(c)
+	name := strings.Replace(filepath.Base(path), filepath.Ext(path), "", 1)
+	return Parse(name, f)
 }